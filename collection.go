@@ -0,0 +1,127 @@
+package orm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// collectionCache caches the collections looked up by name, per DAO.
+// Only the DAOs of the applications given to CacheCollections are cached, until they terminate.
+type collectionCache struct {
+	mu   sync.RWMutex
+	apps map[core.App]*daos.Dao
+	daos map[*daos.Dao]*daoCollections
+}
+
+// daoCollections are the collections cached for a DAO. Its generation is incremented on every invalidation,
+// so that a collection read before an invalidation is not cached after it.
+type daoCollections struct {
+	generation  uint64
+	collections map[string]*models.Collection
+}
+
+var collections = &collectionCache{
+	apps: map[core.App]*daos.Dao{},
+	daos: map[*daos.Dao]*daoCollections{},
+}
+
+// CacheCollections enables the collection cache for the DAO of app, so that Encode, EncodeAll
+// and the finders don't query the collection of the entities again on every call.
+// Cached collections are invalidated when they are updated or deleted through app,
+// and the cache of app is dropped when it terminates.
+func CacheCollections(app core.App) {
+	collections.mu.Lock()
+	defer collections.mu.Unlock()
+
+	if _, ok := collections.apps[app]; ok {
+		return
+	}
+	collections.apps[app] = app.Dao()
+	collections.daos[app.Dao()] = &daoCollections{collections: map[string]*models.Collection{}}
+
+	invalidate := func(e *core.ModelEvent) error {
+		if coll, ok := e.Model.(*models.Collection); ok {
+			collections.invalidate(coll)
+		}
+		return nil
+	}
+	app.OnModelAfterUpdate().Add(invalidate)
+	app.OnModelAfterDelete().Add(invalidate)
+	app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+		collections.remove(app)
+		return nil
+	})
+}
+
+// remove drops the cache of the DAO of app.
+func (c *collectionCache) remove(app core.App) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.daos, c.apps[app])
+	delete(c.apps, app)
+}
+
+// invalidate removes coll from the cache of every DAO, whether it is cached by its name or its id.
+func (c *collectionCache) invalidate(coll *models.Collection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, cached := range c.daos {
+		cached.generation++
+		for nameOrId, cachedColl := range cached.collections {
+			if cachedColl.Id == coll.Id || nameOrId == coll.Name || nameOrId == coll.Id {
+				delete(cached.collections, nameOrId)
+			}
+		}
+	}
+}
+
+// get returns the collection cached for dao under nameOrId, and the generation of the cache of dao.
+// The boolean reports whether dao is cached at all.
+func (c *collectionCache) get(dao *daos.Dao, nameOrId string) (*models.Collection, uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.daos[dao]
+	if !ok {
+		return nil, 0, false
+	}
+
+	return cached.collections[nameOrId], cached.generation, true
+}
+
+// set caches coll for dao under nameOrId, if dao is cached and its cache was not invalidated since generation.
+func (c *collectionCache) set(dao *daos.Dao, nameOrId string, coll *models.Collection, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.daos[dao]; ok && cached.generation == generation {
+		cached.collections[nameOrId] = coll
+	}
+}
+
+// findCollection returns the collection nameOrId, from the cache when dao is cached.
+func findCollection(dao *daos.Dao, nameOrId string) (*models.Collection, error) {
+	coll, generation, cached := collections.get(dao, nameOrId)
+	if coll != nil {
+		return coll, nil
+	}
+
+	coll, err := dao.FindCollectionByNameOrId(nameOrId)
+	if err != nil {
+		return nil, err
+	} else if coll == nil {
+		return nil, fmt.Errorf("collection is nil")
+	}
+
+	if cached {
+		collections.set(dao, nameOrId, coll, generation)
+	}
+
+	return coll, nil
+}
@@ -0,0 +1,189 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCacheCollections(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	CacheCollections(testApp)
+	t.Cleanup(func() { collections.remove(testApp) })
+
+	collectionQueries := 0
+	db := testApp.Dao().ConcurrentDB().(*dbx.DB)
+	db.QueryLogFunc = func(_ context.Context, _ time.Duration, query string, _ *sql.Rows, _ error) {
+		if strings.Contains(query, "_collections") {
+			collectionQueries++
+		}
+	}
+	defer func() { db.QueryLogFunc = nil }()
+
+	entities := make([]*EntityWithAllPBTypes, 100)
+	for i := range entities {
+		entities[i] = &EntityWithAllPBTypes{Text: "foo"}
+	}
+
+	if _, err := EncodeAll(entities, testApp.Dao()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if collectionQueries != 1 {
+		t.Errorf("expected 1 collection query, got %d", collectionQueries)
+	}
+}
+
+func TestCacheCollectionsInvalidation(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	CacheCollections(testApp)
+	t.Cleanup(func() { collections.remove(testApp) })
+
+	entity := EntityWithAllPBTypes{Text: "foo"}
+	if _, err := Encode(&entity, testApp.Dao()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	coll, err := testApp.Dao().FindCollectionByNameOrId(entity.CollectionName())
+	if err != nil {
+		t.Fatalf("could not find collection: %v", err)
+	}
+
+	coll.Schema.RemoveField(coll.Schema.GetFieldByName("text").Id)
+	if err := testApp.Dao().SaveCollection(coll); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	record, err := Encode(&entity, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if actual := record.Collection().Schema.GetFieldByName("text"); actual != nil {
+		t.Errorf("expected updated collection without text field, got %v", actual)
+	}
+
+	if err := testApp.Dao().DeleteCollection(coll); err != nil {
+		t.Fatalf("could not delete collection: %v", err)
+	}
+
+	if _, err := Encode(&entity, testApp.Dao()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestCacheCollectionsConcurrently(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	CacheCollections(testApp)
+	t.Cleanup(func() { collections.remove(testApp) })
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if i%5 == 0 {
+				coll := EntityWithAllPBTypes{}.Collection()
+				collections.invalidate(coll)
+				return
+			}
+
+			entity := EntityWithAllPBTypes{Text: "foo"}
+			if _, err := Encode(&entity, testApp.Dao()); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestFindCollectionWithoutCache(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	coll, err := findCollection(testApp.Dao(), EntityWithAllPBTypes{}.CollectionName())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, _, cached := collections.get(testApp.Dao(), coll.Name); cached {
+		t.Errorf("expected dao not to be cached")
+	}
+}
+
+func TestCacheCollectionsSkipsStaleCollections(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	CacheCollections(testApp)
+	t.Cleanup(func() { collections.remove(testApp) })
+
+	stale := EntityWithAllPBTypes{}.Collection()
+	_, generation, _ := collections.get(testApp.Dao(), stale.Name)
+
+	// the collection is saved between the read of the stale collection and its caching
+	collections.invalidate(stale)
+	collections.set(testApp.Dao(), stale.Name, stale, generation)
+
+	if coll, _, _ := collections.get(testApp.Dao(), stale.Name); coll != nil {
+		t.Errorf("expected stale collection not to be cached, got %v", coll)
+	}
+}
+
+func TestCacheCollectionsDroppedOnTerminate(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dao := testApp.Dao()
+	CacheCollections(testApp)
+
+	if err := testApp.OnTerminate().Trigger(&core.TerminateEvent{App: testApp}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, _, cached := collections.get(dao, EntityWithAllPBTypes{}.CollectionName()); cached {
+		t.Errorf("expected dao not to be cached after terminate")
+	}
+
+	collections.mu.RLock()
+	defer collections.mu.RUnlock()
+	if _, ok := collections.apps[testApp]; ok {
+		t.Errorf("expected app not to be cached after terminate")
+	}
+}
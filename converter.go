@@ -0,0 +1,196 @@
+package orm
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// FieldEncoder is implemented by the field types converting themselves into the value of their column.
+type FieldEncoder interface {
+	EncodeField(field *schema.SchemaField) (any, error)
+}
+
+// FieldDecoder is implemented by the field types setting themselves from the raw value of their column,
+// i.e. a string, a float64, a bool, a types.DateTime, a types.JsonRaw or a types.JsonArray[string]
+// depending on the column type.
+type FieldDecoder interface {
+	DecodeField(field *schema.SchemaField, raw any) error
+}
+
+var (
+	fieldEncoderType = reflect.TypeOf((*FieldEncoder)(nil)).Elem()
+	fieldDecoderType = reflect.TypeOf((*FieldDecoder)(nil)).Elem()
+)
+
+// converter holds the conversion funcs registered for a type.
+type converter struct {
+	encode encodeFunc
+	decode decodeFunc
+}
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[reflect.Type]*converter{}
+)
+
+// RegisterConverter registers the conversion funcs of the fields of type V, for the types which can't implement
+// FieldEncoder and FieldDecoder (e.g. third-party types such as netip.Addr). Either func can be nil to keep
+// the default conversion in that direction. Registered converters take precedence over FieldEncoder and
+// FieldDecoder, which take precedence over the default conversions.
+//
+// Converters are meant to be registered once at startup, before encoding or decoding entities.
+func RegisterConverter[V any](
+	encode func(field *schema.SchemaField, value V) (any, error),
+	decode func(field *schema.SchemaField, raw any) (V, error),
+) {
+	t := reflect.TypeOf((*V)(nil)).Elem()
+	c := &converter{}
+
+	if encode != nil {
+		c.encode = func(field *schema.SchemaField, value reflect.Value) (any, error) {
+			return encode(field, value.Interface().(V))
+		}
+	}
+
+	if decode != nil {
+		c.decode = func(_ *models.Record, field *schema.SchemaField, rawValue any, value reflect.Value, _ *mappingOptions) error {
+			decoded, err := decode(field, rawValue)
+			if err != nil {
+				return err
+			}
+			value.Set(reflect.ValueOf(&decoded).Elem())
+			return nil
+		}
+	}
+
+	convertersMu.Lock()
+	converters[t] = c
+	convertersMu.Unlock()
+
+	resetTypePlans()
+}
+
+// resetTypePlans drops the plans already built, which hold the previous conversion funcs.
+func resetTypePlans() {
+	typePlans.Range(func(key, _ any) bool {
+		typePlans.Delete(key)
+		return true
+	})
+}
+
+// fieldConverters returns the conversion funcs of the fields of type t, and whether they are custom ones.
+// The fields of type *V use the converter registered for V, unless one is registered for *V.
+func fieldConverters(t reflect.Type) (encodeFunc, decodeFunc, bool) {
+	encode, decode := encodeFieldEncoder(t), decodeFieldDecoder(t)
+
+	convertersMu.RLock()
+	c, ok := converters[t]
+	if !ok && t.Kind() == reflect.Pointer {
+		if elem, found := converters[t.Elem()]; found {
+			c, ok = elem.nullable(), true
+		}
+	}
+	if ok {
+		if c.encode != nil {
+			encode = c.encode
+		}
+		if c.decode != nil {
+			decode = c.decode
+		}
+	}
+	convertersMu.RUnlock()
+
+	custom := encode != nil || decode != nil
+
+	if encode == nil {
+		encode = encodeValue
+	}
+	if decode == nil {
+		decode = decodeValue
+	}
+
+	return encode, decode, custom
+}
+
+// nullable returns the conversion funcs of the pointers to the type converted by c. Like the nullable
+// pointers, a nil pointer is encoded as nil and the pointer is left nil on blank columns.
+func (c *converter) nullable() *converter {
+	n := &converter{}
+
+	if c.encode != nil {
+		n.encode = func(field *schema.SchemaField, value reflect.Value) (any, error) {
+			if value.IsNil() {
+				return nil, nil
+			}
+			return c.encode(field, value.Elem())
+		}
+	}
+
+	if c.decode != nil {
+		n.decode = func(record *models.Record, field *schema.SchemaField, rawValue any, value reflect.Value, opts *mappingOptions) error {
+			if isBlankColumnValue(field, rawValue) {
+				value.Set(reflect.Zero(value.Type()))
+				return nil
+			}
+
+			decoded := reflect.New(value.Type().Elem())
+			if err := c.decode(record, field, rawValue, decoded.Elem(), opts); err != nil {
+				return err
+			}
+			value.Set(decoded)
+			return nil
+		}
+	}
+
+	return n
+}
+
+// encodeFieldEncoder returns the encodeFunc of the fields of type t calling their EncodeField method,
+// or nil if t doesn't implement FieldEncoder.
+func encodeFieldEncoder(t reflect.Type) encodeFunc {
+	switch {
+	case t.Implements(fieldEncoderType):
+		return func(field *schema.SchemaField, value reflect.Value) (any, error) {
+			if t.Kind() == reflect.Pointer && value.IsNil() {
+				return nil, nil
+			}
+			return value.Interface().(FieldEncoder).EncodeField(field)
+		}
+
+	case reflect.PointerTo(t).Implements(fieldEncoderType):
+		return func(field *schema.SchemaField, value reflect.Value) (any, error) {
+			if !value.CanAddr() {
+				copied := reflect.New(t)
+				copied.Elem().Set(value)
+				value = copied.Elem()
+			}
+			return value.Addr().Interface().(FieldEncoder).EncodeField(field)
+		}
+	}
+
+	return nil
+}
+
+// decodeFieldDecoder returns the decodeFunc of the fields of type t calling their DecodeField method,
+// or nil if t doesn't implement FieldDecoder.
+func decodeFieldDecoder(t reflect.Type) decodeFunc {
+	switch {
+	case reflect.PointerTo(t).Implements(fieldDecoderType):
+		return func(_ *models.Record, field *schema.SchemaField, rawValue any, value reflect.Value, _ *mappingOptions) error {
+			return value.Addr().Interface().(FieldDecoder).DecodeField(field, rawValue)
+		}
+
+	case t.Kind() == reflect.Pointer && t.Implements(fieldDecoderType):
+		return func(_ *models.Record, field *schema.SchemaField, rawValue any, value reflect.Value, _ *mappingOptions) error {
+			if value.IsNil() {
+				value.Set(reflect.New(t.Elem()))
+			}
+			return value.Interface().(FieldDecoder).DecodeField(field, rawValue)
+		}
+	}
+
+	return nil
+}
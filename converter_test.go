@@ -0,0 +1,158 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// unregisterConverter removes the conversion funcs registered for the fields of type V.
+func unregisterConverter[V any]() {
+	convertersMu.Lock()
+	delete(converters, reflect.TypeOf((*V)(nil)).Elem())
+	convertersMu.Unlock()
+
+	resetTypePlans()
+}
+
+// registerAddrConverter registers a converter of netip.Addr, removed once t ends.
+func registerAddrConverter(t *testing.T) {
+	RegisterConverter(
+		func(field *schema.SchemaField, value netip.Addr) (any, error) {
+			if !value.IsValid() {
+				return "", nil
+			}
+			return value.String(), nil
+		},
+		func(field *schema.SchemaField, raw any) (netip.Addr, error) {
+			if raw == "" {
+				return netip.Addr{}, nil
+			}
+			return netip.ParseAddr(fmt.Sprint(raw))
+		},
+	)
+	t.Cleanup(unregisterConverter[netip.Addr])
+}
+
+// registerCentsConverter registers a converter of Cents, removed once t ends.
+func registerCentsConverter(t *testing.T) {
+	RegisterConverter(
+		func(field *schema.SchemaField, value Cents) (any, error) {
+			return float64(value.Amount) / 100, nil
+		},
+		func(field *schema.SchemaField, raw any) (Cents, error) {
+			units, ok := raw.(float64)
+			if !ok {
+				return Cents{}, fmt.Errorf("could not cast %v to float64", raw)
+			}
+			return Cents{Amount: int64(math.Round(units * 100))}, nil
+		},
+	)
+	t.Cleanup(unregisterConverter[Cents])
+}
+
+func TestConverters(t *testing.T) {
+	registerAddrConverter(t)
+	registerCentsConverter(t)
+	testApp, err := setupTestApp(EntityWithCustomTypes{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	discount := Money(250)
+	entity := EntityWithCustomTypes{
+		Id:       "i7iedw7au80qljq",
+		Price:    Money(1999),
+		Discount: &discount,
+		Address:  netip.MustParseAddr("192.168.1.1"),
+		Tip:      &Cents{Amount: 150},
+	}
+
+	record, err := Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual := record.GetFloat("price"); actual != 19.99 {
+		t.Errorf("expected price 19.99, got %v", actual)
+	}
+	if actual := record.GetFloat("discount"); actual != 2.5 {
+		t.Errorf("expected discount 2.5, got %v", actual)
+	}
+	if actual := record.GetString("address"); actual != "192.168.1.1" {
+		t.Errorf("expected address 192.168.1.1, got %q", actual)
+	}
+	if actual := record.GetFloat("tip"); actual != 1.5 {
+		t.Errorf("expected tip 1.5, got %v", actual)
+	}
+
+	actual := EntityWithCustomTypes{}
+	if err := Decode(record, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, entity) {
+		t.Errorf("expected %v, got %v", entity, actual)
+	}
+}
+
+func TestConvertersWithRepository(t *testing.T) {
+	registerAddrConverter(t)
+	registerCentsConverter(t)
+	testApp, err := setupTestApp(EntityWithCustomTypes{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	repository := NewRepository[EntityWithCustomTypes](testApp.Dao(), Strict())
+
+	entity := EntityWithCustomTypes{Price: Money(1000)}
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := repository.FindById(entity.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual.Price != Money(1000) || actual.Discount == nil || *actual.Discount != 0 || actual.Address.IsValid() || actual.Tip != nil {
+		t.Errorf("unexpected entity %v", actual)
+	}
+
+	if err := Validate[EntityWithCustomTypes](testApp.Dao()); err != nil {
+		t.Errorf("expected no validation error, got %v", err)
+	}
+}
+
+func TestConvertersErrors(t *testing.T) {
+	registerAddrConverter(t)
+	registerCentsConverter(t)
+	testApp, err := setupTestApp(EntityWithCustomTypes{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	record, err := Encode(&EntityWithCustomTypes{}, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	record.Set("address", "not an address")
+
+	var mappingErr *MappingError
+	if err := Decode(record, &EntityWithCustomTypes{}, Strict()); !errors.As(err, &mappingErr) {
+		t.Fatalf("expected *MappingError, got %v", err)
+	}
+
+	if len(mappingErr.Fields) != 1 || mappingErr.Fields[0].Field != "Address" {
+		t.Errorf("expected Address field error, got %v", mappingErr)
+	}
+}
@@ -1,7 +1,11 @@
 package orm
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/pocketbase/pocketbase/models"
@@ -9,8 +13,21 @@ import (
 	"github.com/pocketbase/pocketbase/tools/types"
 )
 
+// asAdmin is the request information of an admin, to which no access rule applies.
+var asAdmin = &models.RequestData{Admin: &models.Admin{}}
+
 var _ Entity = EntityWithAllPBTypes{}
 
+// User maps the users auth collection of the PocketBase test data.
+type User struct {
+	Id   string `orm:"id"`
+	Name string `orm:"name"`
+}
+
+func (_ User) CollectionName() string {
+	return "users"
+}
+
 type StringUnderlyingType string
 
 const (
@@ -188,3 +205,582 @@ func init() {
 	recordExample.Set("single_select", "foo")
 	recordExample.Set("multiple_select", `["bar","baz"]`)
 }
+
+var (
+	_ Entity = Author{}
+	_ Entity = Tag{}
+	_ Entity = Book{}
+)
+
+type Author struct {
+	Id   string `orm:"id"`
+	Name string `orm:"name"`
+}
+
+func (_ Author) CollectionName() string {
+	return "authors"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ Author) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "name", Type: schema.FieldTypeText},
+	)
+
+	coll := &models.Collection{Name: "authors", Schema: _schema}
+	coll.Id = "authors00000001"
+	return coll
+}
+
+type Tag struct {
+	Id    string  `orm:"id"`
+	Label string  `orm:"label"`
+	Owner *Author `orm:"owner"`
+}
+
+func (_ Tag) CollectionName() string {
+	return "tags"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ Tag) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "label", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "owner", Type: schema.FieldTypeRelation, Options: &schema.RelationOptions{CollectionId: "authors00000001", MinSelect: pointer(0), MaxSelect: pointer(1)}},
+	)
+
+	coll := &models.Collection{Name: "tags", Schema: _schema}
+	coll.Id = "tags00000000001"
+	return coll
+}
+
+type Book struct {
+	Id     string  `orm:"id"`
+	Title  string  `orm:"title"`
+	Author *Author `orm:"author"`
+	Tags   []*Tag  `orm:"tags"`
+}
+
+func (_ Book) CollectionName() string {
+	return "books"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ Book) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "author", Type: schema.FieldTypeRelation, Options: &schema.RelationOptions{CollectionId: "authors00000001", MinSelect: pointer(0), MaxSelect: pointer(1)}},
+		&schema.SchemaField{Name: "tags", Type: schema.FieldTypeRelation, Options: &schema.RelationOptions{CollectionId: "tags00000000001", MinSelect: pointer(0), MaxSelect: pointer(10)}},
+	)
+
+	coll := &models.Collection{Name: "books", Schema: _schema}
+	coll.Id = "books0000000001"
+	return coll
+}
+
+var _ Entity = EntityWithFiles{}
+
+type EntityWithFiles struct {
+	Id          string   `orm:"id"`
+	Avatar      File     `orm:"avatar"`
+	Attachments []File   `orm:"attachments"`
+	Cover       string   `orm:"cover"`
+	Documents   []string `orm:"documents"`
+}
+
+func (_ EntityWithFiles) CollectionName() string {
+	return "files"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithFiles) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "avatar", Type: schema.FieldTypeFile, Options: &schema.FileOptions{MaxSelect: 1, MaxSize: 1024}},
+		&schema.SchemaField{Name: "attachments", Type: schema.FieldTypeFile, Options: &schema.FileOptions{MaxSelect: 10, MaxSize: 1024}},
+		&schema.SchemaField{Name: "cover", Type: schema.FieldTypeFile, Options: &schema.FileOptions{MaxSelect: 1, MaxSize: 1024}},
+		&schema.SchemaField{Name: "documents", Type: schema.FieldTypeFile, Options: &schema.FileOptions{MaxSelect: 10, MaxSize: 1024}},
+	)
+
+	return &models.Collection{Name: "files", Schema: _schema}
+}
+
+var _ Entity = EntityWithSystemFields{}
+
+type EntityWithSystemFields struct {
+	Id       string          `orm:"id"`
+	Title    string          `orm:"title"`
+	Created  time.Time       `orm:"created"`
+	Updated  *types.DateTime `orm:"updated"`
+	CollId   string          `orm:"collectionId"`
+	CollName string          `orm:"collectionName"`
+}
+
+func (_ EntityWithSystemFields) CollectionName() string {
+	return "systems"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithSystemFields) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+	)
+
+	coll := &models.Collection{Name: "systems", Schema: _schema}
+	coll.Id = "systems00000001"
+	return coll
+}
+
+var _ Entity = EntityWithSchemaOptions{}
+
+type EntityWithSchemaOptions struct {
+	Id        string          `orm:"id"`
+	Title     string          `orm:"title,required,min=3,max=100"`
+	Slug      string          `orm:"slug,unique"`
+	Email     string          `orm:"email,type=email"`
+	Rating    float64         `orm:"rating,min=0,max=5"`
+	Status    string          `orm:"status,type=select,values=draft|published"`
+	Labels    []string        `orm:"labels,type=select,values=a|b|c,maxSelect=2"`
+	Owner     string          `orm:"owner,type=relation,collection=authors"`
+	Authors   []*Author       `orm:"authors,maxSelect=3"`
+	Cover     File            `orm:"cover,maxSize=1024"`
+	Documents []File          `orm:"documents"`
+	Published *types.DateTime `orm:"published"`
+	Meta      Obj             `orm:"meta"`
+	Created   time.Time       `orm:"created"`
+}
+
+func (_ EntityWithSchemaOptions) CollectionName() string {
+	return "options"
+}
+
+var _ Entity = EntityWithSchemaMismatches{}
+
+type EntityWithSchemaMismatches struct {
+	Id             string `orm:"id"`
+	Text           int    `orm:"text"`
+	Renamed        string `orm:"renamed"`
+	MultipleSelect string `orm:"multiple_select"`
+	Bool           bool   `orm:"bool,omitemty"`
+}
+
+func (_ EntityWithSchemaMismatches) CollectionName() string {
+	return "foo"
+}
+
+var _ Entity = EntityWithSystemFieldsMismatches{}
+
+type EntityWithSystemFieldsMismatches struct {
+	Id           string    `orm:"id"`
+	Created      int       `orm:"created"`
+	Updated      time.Time `orm:"updated"`
+	CollectionId bool      `orm:"collectionId"`
+}
+
+func (_ EntityWithSystemFieldsMismatches) CollectionName() string {
+	return "foo"
+}
+
+var _ Entity = BookWithTagAsAuthor{}
+
+type BookWithTagAsAuthor struct {
+	Id     string `orm:"id"`
+	Author *Tag   `orm:"author"`
+}
+
+func (_ BookWithTagAsAuthor) CollectionName() string {
+	return "books"
+}
+
+var _ Entity = TagWithNumericLabel{}
+
+type TagWithNumericLabel struct {
+	Id    string `orm:"id"`
+	Label int    `orm:"label"`
+}
+
+func (_ TagWithNumericLabel) CollectionName() string {
+	return "tags"
+}
+
+var _ Entity = BookWithNumericLabelTags{}
+
+type BookWithNumericLabelTags struct {
+	Id   string                 `orm:"id"`
+	Tags []*TagWithNumericLabel `orm:"tags"`
+}
+
+func (_ BookWithNumericLabelTags) CollectionName() string {
+	return "books"
+}
+
+// Money is an amount of cents, stored as a number of units.
+type Money int64
+
+func (m Money) EncodeField(field *schema.SchemaField) (any, error) {
+	if field.Type != schema.FieldTypeNumber {
+		return nil, fmt.Errorf("money can't be stored in a %s column", field.Type)
+	}
+	return float64(m) / 100, nil
+}
+
+func (m *Money) DecodeField(field *schema.SchemaField, raw any) error {
+	units, ok := raw.(float64)
+	if !ok {
+		return fmt.Errorf("could not cast %v to float64", raw)
+	}
+	*m = Money(math.Round(units * 100))
+	return nil
+}
+
+// Cents is an amount of cents, converted by a registered converter.
+type Cents struct {
+	Amount int64
+}
+
+var _ Entity = EntityWithCustomTypes{}
+
+type EntityWithCustomTypes struct {
+	Id       string     `orm:"id"`
+	Price    Money      `orm:"price"`
+	Discount *Money     `orm:"discount"`
+	Address  netip.Addr `orm:"address"`
+	Tip      *Cents     `orm:"tip"`
+}
+
+func (_ EntityWithCustomTypes) CollectionName() string {
+	return "customs"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithCustomTypes) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "price", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "discount", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "address", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "tip", Type: schema.FieldTypeNumber},
+	)
+
+	return &models.Collection{Name: "customs", Schema: _schema}
+}
+
+// Level is stored by its name, thanks to encoding.TextMarshaler.
+type Level int
+
+const (
+	Low Level = iota
+	High
+)
+
+func (l Level) MarshalText() ([]byte, error) {
+	switch l {
+	case Low:
+		return []byte("low"), nil
+	case High:
+		return []byte("high"), nil
+	}
+	return nil, fmt.Errorf("unknown level %d", l)
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = Low
+	case "high":
+		*l = High
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+	return nil
+}
+
+// Code is a string based type whose MarshalText takes precedence over its underlying string.
+type Code string
+
+func (c Code) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(c))), nil
+}
+
+func (c *Code) UnmarshalText(text []byte) error {
+	*c = Code(strings.ToLower(string(text)))
+	return nil
+}
+
+// Point is stored as [x, y], its MarshalJSON taking precedence over its MarshalText in JSON columns.
+type Point struct {
+	X, Y int
+}
+
+func (p Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{p.X, p.Y})
+}
+
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var xy [2]int
+	if err := json.Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	p.X, p.Y = xy[0], xy[1]
+	return nil
+}
+
+func (p Point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (p *Point) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+var _ Entity = EntityWithMarshalers{}
+
+type EntityWithMarshalers struct {
+	Id       string  `orm:"id"`
+	Level    Level   `orm:"level"`
+	Levels   []Level `orm:"levels"`
+	Backup   *Level  `orm:"backup"`
+	Code     Code    `orm:"code"`
+	Position Point   `orm:"position"`
+	Origin   Point   `orm:"origin"`
+}
+
+func (_ EntityWithMarshalers) CollectionName() string {
+	return "marshalers"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithMarshalers) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "level", Type: schema.FieldTypeSelect, Options: &schema.SelectOptions{MaxSelect: 1, Values: []string{"low", "high"}}},
+		&schema.SchemaField{Name: "levels", Type: schema.FieldTypeSelect, Options: &schema.SelectOptions{MaxSelect: 2, Values: []string{"low", "high"}}},
+		&schema.SchemaField{Name: "backup", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "code", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "position", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{}},
+		&schema.SchemaField{Name: "origin", Type: schema.FieldTypeText},
+	)
+
+	return &models.Collection{Name: "marshalers", Schema: _schema}
+}
+
+// BaseEntity holds the system fields shared by the entities embedding it.
+type BaseEntity struct {
+	Id      string    `orm:"id"`
+	Created time.Time `orm:"created"`
+	Updated time.Time `orm:"updated"`
+}
+
+type CollectionInfo struct {
+	CollName string `orm:"collectionName"`
+}
+
+var _ Entity = EntityWithEmbeds{}
+
+type EntityWithEmbeds struct {
+	BaseEntity
+	*CollectionInfo
+	Title string `orm:"title"`
+}
+
+func (_ EntityWithEmbeds) CollectionName() string {
+	return "systems"
+}
+
+type Geo struct {
+	Lat float64 `orm:"lat"`
+	Lng float64 `orm:"lng"`
+}
+
+type Address struct {
+	Street string `orm:"street"`
+	City   string `orm:"city"`
+	Geo    Geo    `orm:"geo,inline"`
+}
+
+var _ Entity = EntityWithInlineStructs{}
+
+type EntityWithInlineStructs struct {
+	Id      string   `orm:"id"`
+	Name    string   `orm:"name"`
+	Address Address  `orm:"address,inline,prefix=addr_"`
+	Billing *Address `orm:"billing,inline"`
+}
+
+func (_ EntityWithInlineStructs) CollectionName() string {
+	return "contacts"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithInlineStructs) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "name", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "addr_street", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "addr_city", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "addr_geo_lat", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "addr_geo_lng", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "billing_street", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "billing_city", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "billing_geo_lat", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "billing_geo_lng", Type: schema.FieldTypeNumber},
+	)
+
+	return &models.Collection{Name: "contacts", Schema: _schema}
+}
+
+var _ Entity = EntityWithNullablePointers{}
+
+type EntityWithNullablePointers struct {
+	Id             string                `orm:"id"`
+	Text           *string               `orm:"text"`
+	TextUnderlying *StringUnderlyingType `orm:"text_underlying"`
+	Int            *int                  `orm:"number_int"`
+	Float          *float64              `orm:"number_float64"`
+	Bool           *bool                 `orm:"bool"`
+	Object         *Obj                  `orm:"json_object"`
+	SingleSelect   *string               `orm:"single_select"`
+	MultipleSelect *[]string             `orm:"multiple_select"`
+}
+
+func (_ EntityWithNullablePointers) CollectionName() string {
+	return "foo"
+}
+
+var _ Entity = PartialEntity{}
+
+type PartialEntity struct {
+	Id        string `orm:"id"`
+	Text      string `orm:"text"`
+	NumberInt int    `orm:"number_int"`
+}
+
+func (_ PartialEntity) CollectionName() string {
+	return "foo"
+}
+
+var _ Entity = TrackedEntity{}
+
+type TrackedEntity struct {
+	Tracked
+	Id        string `orm:"id"`
+	Text      string `orm:"text"`
+	NumberInt int    `orm:"number_int"`
+	Bool      bool   `orm:"bool"`
+	Object    Obj    `orm:"json_object"`
+}
+
+func (_ TrackedEntity) CollectionName() string {
+	return "foo"
+}
+
+var _ Entity = EntityWithDates{}
+
+type EntityWithDates struct {
+	Id          string          `orm:"id"`
+	Time        time.Time       `orm:"time"`
+	TimePtr     *time.Time      `orm:"time_ptr"`
+	DateTime    types.DateTime  `orm:"datetime"`
+	DateTimePtr *types.DateTime `orm:"datetime_ptr"`
+	Created     time.Time       `orm:"created"`
+}
+
+func (_ EntityWithDates) CollectionName() string {
+	return "dates"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithDates) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "time", Type: schema.FieldTypeDate},
+		&schema.SchemaField{Name: "time_ptr", Type: schema.FieldTypeDate},
+		&schema.SchemaField{Name: "datetime", Type: schema.FieldTypeDate},
+		&schema.SchemaField{Name: "datetime_ptr", Type: schema.FieldTypeDate},
+	)
+
+	return &models.Collection{Name: "dates", Schema: _schema}
+}
+
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusPublished Status = "published"
+)
+
+func (Status) EnumValues() []string {
+	return []string{"draft", "published"}
+}
+
+type Label string
+
+var _ Entity = EntityWithEnums{}
+
+type EntityWithEnums struct {
+	Id     string  `orm:"id"`
+	Status Status  `orm:"status"`
+	Labels []Label `orm:"labels"`
+}
+
+func (_ EntityWithEnums) CollectionName() string {
+	return "enums"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithEnums) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "status", Type: schema.FieldTypeSelect, Options: &schema.SelectOptions{MaxSelect: 1, Values: []string{"draft", "published", "archived"}}},
+		&schema.SchemaField{Name: "labels", Type: schema.FieldTypeSelect, Options: &schema.SelectOptions{MaxSelect: 3, Values: []string{"a", "b", "c"}}},
+	)
+
+	return &models.Collection{Name: "enums", Schema: _schema}
+}
+
+var _ Entity = BookWithRefs{}
+
+type BookWithRefs struct {
+	Id     string      `orm:"id"`
+	Title  string      `orm:"title"`
+	Author Ref[Author] `orm:"author"`
+	Tags   Refs[Tag]   `orm:"tags"`
+}
+
+func (_ BookWithRefs) CollectionName() string {
+	return "books"
+}
+
+var _ Entity = BookWithTagRefAsAuthor{}
+
+type BookWithTagRefAsAuthor struct {
+	Id     string   `orm:"id"`
+	Author Ref[Tag] `orm:"author"`
+}
+
+func (_ BookWithTagRefAsAuthor) CollectionName() string {
+	return "books"
+}
+
+var _ Entity = EntityWithNumbers{}
+
+type EntityWithNumbers struct {
+	Id     string  `orm:"id"`
+	Small  int8    `orm:"small"`
+	Count  uint    `orm:"count"`
+	Big    uint64  `orm:"big"`
+	Whole  float64 `orm:"whole,noDecimal"`
+	Rating float32 `orm:"rating"`
+}
+
+func (_ EntityWithNumbers) CollectionName() string {
+	return "numbers"
+}
+
+// Collection - for testing purpose only, note that this method is not part of Entity interface.
+func (_ EntityWithNumbers) Collection() *models.Collection {
+	_schema := schema.NewSchema(
+		&schema.SchemaField{Name: "small", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "count", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "big", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "whole", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "rating", Type: schema.FieldTypeNumber, Options: &schema.NumberOptions{Min: pointer(1.0), Max: pointer(5.0)}},
+	)
+
+	return &models.Collection{Name: "numbers", Schema: _schema}
+}
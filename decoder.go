@@ -3,16 +3,14 @@ package orm
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"reflect"
-	"time"
 
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/models/schema"
 	"github.com/pocketbase/pocketbase/tools/types"
 )
 
-func DecodeAll[T Entity](records []*models.Record, entities []*T) error {
+func DecodeAll[T Entity](records []*models.Record, entities []*T, opts ...Option) error {
 	if len(records) != len(entities) {
 		return fmt.Errorf("length mismatch between records and entities provided")
 	}
@@ -23,7 +21,7 @@ func DecodeAll[T Entity](records []*models.Record, entities []*T) error {
 			entities[i] = &zeroValue
 		}
 
-		if err := Decode(record, entities[i]); err != nil {
+		if err := Decode(record, entities[i], opts...); err != nil {
 			return fmt.Errorf("could not decode %d element: %w", i, err)
 		}
 	}
@@ -31,7 +29,9 @@ func DecodeAll[T Entity](records []*models.Record, entities []*T) error {
 	return nil
 }
 
-func Decode[T Entity](record *models.Record, entity *T) error {
+// Decode sets the fields of entity to the values of record. The fields which can't be decoded are skipped
+// and reported to the error handler, unless the Strict option is given, see MappingError.
+func Decode[T Entity](record *models.Record, entity *T, opts ...Option) error {
 	if record == nil {
 		return fmt.Errorf("could not decode nil record")
 	}
@@ -40,8 +40,6 @@ func Decode[T Entity](record *models.Record, entity *T) error {
 		return fmt.Errorf("record's collection is nil")
 	}
 
-	collSchema := record.Collection().Schema
-
 	if entity == nil {
 		var zeroValue T
 		entity = &zeroValue
@@ -54,130 +52,266 @@ func Decode[T Entity](record *models.Record, entity *T) error {
 		return fmt.Errorf("entity given is not a structure")
 	}
 
+	o := newMappingOptions(opts)
+	return o.handle(decodeStruct(record, s, o))
+}
+
+// decodeStruct decodes record into the structure value s.
+// It returns a *MappingError listing the fields which could not be decoded, or a fatal error.
+func decodeStruct(record *models.Record, s reflect.Value, opts *mappingOptions) error {
+	collSchema := record.Collection().Schema
 	recordMap := RecordsColumnValueMap(record)
+	mappingErr := &MappingError{Entity: s.Type().Name()}
+	plan := planOf(s.Type())
+	nilPointers := setNilInlinedPointers(plan, s, recordMap, collSchema)
+
+	for _, fp := range plan.fields {
+		if isPromotedFrom(fp.index, nilPointers) {
+			continue
+		}
+
+		if fp.readOnly {
+			if err := decodeReadOnlyColumn(record, fp.columnName, fp.settableValue(s), opts.location); err != nil {
+				if !mappingErr.add(fp, readOnlyColumnType(fp.columnName), err) {
+					return err
+				}
+			}
+			continue
+		}
 
-	numField := reflect.TypeOf(entity).Elem().NumField()
-	for i := 0; i < numField; i++ {
-		field := reflect.TypeOf(entity).Elem().Field(i)
-		columnName := extractOrmNameFromTag(string(field.Tag))
-		rawValue, ok := recordMap[columnName]
+		rawValue, ok := recordMap[fp.columnName]
 		if !ok {
 			continue
 		}
 
-		fieldType := fieldFromColumnName(collSchema, columnName)
+		fieldType := fieldFromColumnName(collSchema, fp.columnName)
 		if fieldType == nil {
 			continue
 		}
 
-		entityField := reflect.ValueOf(entity).Elem().Field(i)
-
-		switch fieldType.Type {
-		case schema.FieldTypeText, schema.FieldTypeEmail, schema.FieldTypeUrl, schema.FieldTypeEditor:
-			if strVal, ok := rawValue.(string); ok {
-				entityField.SetString(strVal)
+		if err := fp.decode(record, fieldType, rawValue, fp.settableValue(s), opts); err != nil {
+			if !mappingErr.add(fp, fieldType.Type, err) {
+				return err
 			}
-			break
+		}
+	}
 
-		case schema.FieldTypeNumber:
-			if f64Val, ok := rawValue.(float64); ok {
-				switch entityField.Kind() {
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-					entityField.SetInt(int64(f64Val))
-				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-					entityField.SetUint(uint64(f64Val))
-				case reflect.Float32, reflect.Float64:
-					entityField.SetFloat(f64Val)
-				}
-			}
-			break
+	track(s, record)
+	return mappingErr.errOrNil()
+}
 
-		case schema.FieldTypeBool:
-			if boolVal, ok := rawValue.(bool); ok {
-				entityField.SetBool(boolVal)
-			}
-			break
+// setNilInlinedPointers sets to nil the inlined structure pointers of s whose columns are all blank in
+// recordMap, and returns their indexes. The other ones are allocated by the decoding of their fields.
+func setNilInlinedPointers(plan *typePlan, s reflect.Value, recordMap map[string]any, collSchema schema.Schema) [][]int {
+	nilPointers := [][]int{}
 
-		case schema.FieldTypeDate:
-			datetime, err := time.Parse(types.DefaultDateLayout, fmt.Sprint(rawValue))
-			if err != nil {
-				break
-			}
-			entityField.Set(reflect.ValueOf(&datetime))
-			break
+	for _, ptr := range plan.inlinedPointers {
+		if isPromotedFrom(ptr.index, nilPointers) || !hasBlankColumns(ptr.columns, recordMap, collSchema) {
+			continue
+		}
 
-		case schema.FieldTypeJson:
-			bytesVal := []byte(fmt.Sprint(rawValue))
+		nilPointers = append(nilPointers, ptr.index)
+		if v, err := s.FieldByIndexErr(ptr.index); err == nil {
+			v.Set(reflect.Zero(v.Type()))
+		}
+	}
 
-			if len(bytesVal) == 0 {
-				break
-			}
+	return nilPointers
+}
 
-			val := reflect.New(entityField.Type()).Interface()
-			if err := json.Unmarshal(bytesVal, val); err != nil {
-				log.Printf("could not unmarshal %s: %v", string(bytesVal), err)
-				break
-			}
-			entityField.Set(reflect.ValueOf(val).Elem())
+// hasBlankColumns reports whether all the columns are blank in recordMap, or unknown.
+func hasBlankColumns(columns []string, recordMap map[string]any, collSchema schema.Schema) bool {
+	for _, column := range columns {
+		fieldType := fieldFromColumnName(collSchema, column)
+		if fieldType == nil {
+			continue
+		}
+
+		if rawValue, ok := recordMap[column]; ok && !isBlankColumnValue(fieldType, rawValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeValue is the default decodeFunc, converting rawValue according to the PocketBase type of fieldType.
+func decodeValue(record *models.Record, fieldType *schema.SchemaField, rawValue any, entityField reflect.Value, opts *mappingOptions) error {
+	columnName := fieldType.Name
+
+	// nullable pointers are left nil on blank columns, and point to the decoded value otherwise
+	if isNullablePointer(fieldType, entityField.Type()) {
+		if isBlankColumnValue(fieldType, rawValue) {
+			entityField.Set(reflect.Zero(entityField.Type()))
+			return nil
+		}
+
+		value := reflect.New(entityField.Type().Elem())
+		if err := decodeValue(record, fieldType, rawValue, value.Elem(), opts); err != nil {
+			return err
+		}
+		entityField.Set(value)
+		return nil
+	}
+
+	// no value (e.g. a NULL column)
+	if rawValue == nil {
+		return nil
+	}
+
+	switch fieldType.Type {
+	case schema.FieldTypeText, schema.FieldTypeEmail, schema.FieldTypeUrl, schema.FieldTypeEditor:
+		if !isTextType(entityField.Type()) {
+			return kindError(fieldType.Type, entityField.Type())
+		}
+
+		strVal, ok := rawValue.(string)
+		if !ok {
+			return fmt.Errorf("could not cast %v to string", rawValue)
+		}
+		_, err := setText(entityField, strVal)
+		return err
+
+	case schema.FieldTypeNumber:
+		f64Val, ok := rawValue.(float64)
+		if !ok {
+			return fmt.Errorf("could not cast %v to float64", rawValue)
+		}
+
+		if err := checkNumberRange(fieldType, f64Val); err != nil {
+			return err
+		}
+
+		if ok, err := setNumber(entityField, f64Val); !ok {
+			return kindError(fieldType.Type, entityField.Type())
+		} else if err != nil {
+			return err
+		}
+
+	case schema.FieldTypeBool:
+		if entityField.Kind() != reflect.Bool {
+			return kindError(fieldType.Type, entityField.Type())
+		}
+
+		boolVal, ok := rawValue.(bool)
+		if !ok {
+			return fmt.Errorf("could not cast %v to bool", rawValue)
+		}
+		entityField.SetBool(boolVal)
+
+	case schema.FieldTypeDate:
+		if entityField.Type() != timeType && entityField.Type() != dateTimeType {
+			return kindError(fieldType.Type, entityField.Type())
+		}
+
+		dt, err := types.ParseDateTime(rawValue)
+		if err != nil {
+			return fmt.Errorf("could not parse date %v: %w", rawValue, err)
+		}
+		setDateTime(entityField, dt, opts.location)
+
+	case schema.FieldTypeJson:
+		// json.Unmarshal honors json.Unmarshaler, then encoding.TextUnmarshaler
+		bytesVal := []byte(fmt.Sprint(rawValue))
+
+		if len(bytesVal) == 0 {
 			break
+		}
 
-		case schema.FieldTypeRelation:
-			if !(fieldType.Options.(*schema.RelationOptions)).IsMultiple() {
-				if strVal, ok := rawValue.(string); ok {
-					entityField.SetString(strVal)
-				}
-				break
-			}
+		val := reflect.New(entityField.Type()).Interface()
+		if err := json.Unmarshal(bytesVal, val); err != nil {
+			return fmt.Errorf("could not unmarshal %s: %w", string(bytesVal), err)
+		}
+		entityField.Set(reflect.ValueOf(val).Elem())
 
-			rawJsonArray, ok := rawValue.(types.JsonArray[string])
+	case schema.FieldTypeRelation:
+		if !(fieldType.Options.(*schema.RelationOptions)).IsMultiple() {
+			strVal, ok := rawValue.(string)
 			if !ok {
-				log.Printf("could not cast %v to types.JsonArray[string]", rawValue)
-				break
+				return fmt.Errorf("could not cast %v to string", rawValue)
 			}
 
-			data, err := rawJsonArray.MarshalJSON()
-			if err != nil {
-				log.Printf("could not marshal %v", rawJsonArray)
-				break
+			if isRelated(entityField.Type()) {
+				return decodeSingleRelation(record, columnName, strVal, entityField, opts)
 			}
 
-			strSlice := []string{}
-			if err := json.Unmarshal(data, &strSlice); err != nil {
-				log.Printf("could not unmarshal %v", string(data))
-				break
+			if ok, err := setText(entityField, strVal); !ok {
+				return kindError(fieldType.Type, entityField.Type())
+			} else if err != nil {
+				return err
 			}
-
-			entityField.Set(reflect.ValueOf(strSlice))
 			break
+		}
 
-		case schema.FieldTypeSelect:
-			if !(fieldType.Options.(*schema.SelectOptions)).IsMultiple() {
-				if strVal, ok := rawValue.(string); ok {
-					entityField.SetString(strVal)
-				}
-				break
-			}
+		strSlice, ok := stringSliceFromRawValue(rawValue)
+		if !ok {
+			return fmt.Errorf("could not cast %v to types.JsonArray[string]", rawValue)
+		}
+
+		if isRelatedSlice(entityField.Type()) {
+			return decodeMultipleRelation(record, columnName, strSlice, entityField, opts)
+		}
 
-			rawJsonArray, ok := rawValue.(types.JsonArray[string])
+		if ok, err := setTexts(entityField, strSlice); !ok {
+			return kindError(fieldType.Type, entityField.Type())
+		} else if err != nil {
+			return err
+		}
+
+	case schema.FieldTypeFile:
+		names := []string{}
+		if !(fieldType.Options.(*schema.FileOptions)).IsMultiple() {
+			strVal, ok := rawValue.(string)
+			if !ok {
+				return fmt.Errorf("could not cast %v to string", rawValue)
+			}
+			if strVal != "" {
+				names = append(names, strVal)
+			}
+		} else {
+			strSlice, ok := stringSliceFromRawValue(rawValue)
 			if !ok {
-				log.Printf("could not cast %v to types.JsonArray[string]", rawValue)
-				break
+				return fmt.Errorf("could not cast %v to a slice of filenames", rawValue)
 			}
+			names = strSlice
+		}
 
-			data, err := rawJsonArray.MarshalJSON()
-			if err != nil {
-				log.Printf("could not marshal %v", rawJsonArray)
-				break
+		if !setFileNames(entityField, names) {
+			return kindError(fieldType.Type, entityField.Type())
+		}
+
+	case schema.FieldTypeSelect:
+		if !(fieldType.Options.(*schema.SelectOptions)).IsMultiple() {
+			if !isTextType(entityField.Type()) {
+				return kindError(fieldType.Type, entityField.Type())
 			}
 
-			strSlice := []string{}
-			if err := json.Unmarshal(data, &strSlice); err != nil {
-				log.Printf("could not unmarshal %v", string(data))
-				break
+			strVal, ok := rawValue.(string)
+			if !ok {
+				return fmt.Errorf("could not cast %v to string", rawValue)
 			}
+			if err := checkSelectValues(fieldType, strVal); err != nil {
+				return err
+			}
+			_, err := setText(entityField, strVal)
+			return err
+		}
 
-			entityField.Set(reflect.ValueOf(strSlice))
-			break
+		strSlice, ok := stringSliceFromRawValue(rawValue)
+		if !ok {
+			return fmt.Errorf("could not cast %v to types.JsonArray[string]", rawValue)
+		}
+
+		if !isTextSlice(entityField.Type()) {
+			return kindError(fieldType.Type, entityField.Type())
+		}
+		if err := checkSelectValues(fieldType, strSlice...); err != nil {
+			return err
+		}
+
+		if ok, err := setTexts(entityField, strSlice); !ok {
+			return kindError(fieldType.Type, entityField.Type())
+		} else if err != nil {
+			return err
 		}
 	}
 
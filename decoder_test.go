@@ -1,10 +1,14 @@
 package orm
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 func TestDecode(t *testing.T) {
@@ -42,3 +46,454 @@ func TestDecodeAll(t *testing.T) {
 	}
 
 }
+
+func TestDecodeRelationsWithoutExpand(t *testing.T) {
+	r := models.NewRecord(Book{}.Collection())
+	r.SetId("book1")
+	r.Set("title", "foo")
+	r.Set("author", "author1")
+	r.Set("tags", `["tag1","tag2"]`)
+
+	entity := Book{}
+	if err := Decode(r, &entity); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	expected := Book{
+		Id:     "book1",
+		Title:  "foo",
+		Author: &Author{Id: "author1"},
+		Tags:   []*Tag{{Id: "tag1"}, {Id: "tag2"}},
+	}
+	if !reflect.DeepEqual(entity, expected) {
+		t.Errorf("expected %v, got %v", expected, entity)
+	}
+}
+
+func TestDecodeRelationsWithExpand(t *testing.T) {
+	author := models.NewRecord(Author{}.Collection())
+	author.SetId("author1")
+	author.Set("name", "bar")
+
+	tag1 := models.NewRecord(Tag{}.Collection())
+	tag1.SetId("tag1")
+	tag1.Set("label", "qux")
+	tag1.Set("owner", "author1")
+	tag1.SetExpand(map[string]any{"owner": author})
+
+	r := models.NewRecord(Book{}.Collection())
+	r.SetId("book1")
+	r.Set("title", "foo")
+	r.Set("author", "author1")
+	r.Set("tags", `["tag1","tag2"]`)
+	r.SetExpand(map[string]any{
+		"author": author,
+		"tags":   []*models.Record{tag1},
+	})
+
+	entity := Book{}
+	if err := Decode(r, &entity); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	expected := Book{
+		Id:     "book1",
+		Title:  "foo",
+		Author: &Author{Id: "author1", Name: "bar"},
+		Tags: []*Tag{
+			{Id: "tag1", Label: "qux", Owner: &Author{Id: "author1", Name: "bar"}},
+			{Id: "tag2"},
+		},
+	}
+	if !reflect.DeepEqual(entity, expected) {
+		t.Errorf("expected %v, got %v", expected, entity)
+	}
+}
+
+func TestDecodeRelationsWithExpandFromAnotherCollection(t *testing.T) {
+	tag := models.NewRecord(Tag{}.Collection())
+	tag.SetId("author1")
+
+	r := models.NewRecord(Book{}.Collection())
+	r.Set("author", "author1")
+	r.SetExpand(map[string]any{"author": tag})
+
+	if err := Decode(r, &Book{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestDecodeSystemFields(t *testing.T) {
+	created, err := types.ParseDateTime("2023-05-12 19:51:05.000Z")
+	if err != nil {
+		t.Fatalf("could not parse date: %v", err)
+	}
+	updated, err := types.ParseDateTime("2023-06-01 08:00:00.000Z")
+	if err != nil {
+		t.Fatalf("could not parse date: %v", err)
+	}
+
+	r := models.NewRecord(EntityWithSystemFields{}.Collection())
+	r.SetId("i7iedw7au80qljq")
+	r.Set("title", "foo")
+	r.Created = created
+	r.Updated = updated
+
+	entity := EntityWithSystemFields{}
+	if err := Decode(r, &entity); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	expected := EntityWithSystemFields{
+		Id:       "i7iedw7au80qljq",
+		Title:    "foo",
+		Created:  created.Time(),
+		Updated:  &updated,
+		CollId:   "systems00000001",
+		CollName: "systems",
+	}
+	if !reflect.DeepEqual(entity, expected) {
+		t.Errorf("expected %v, got %v", expected, entity)
+	}
+}
+
+func TestDecodeStringBasedSlices(t *testing.T) {
+	type entityWithStringBasedSlices struct {
+		MultipleRelation []StringUnderlyingType `orm:"multiple_relation"`
+		MultipleSelect   []StringUnderlyingType `orm:"multiple_select"`
+	}
+
+	entity := entityWithStringBasedSlices{}
+	if err := decodeStruct(recordExample, reflect.ValueOf(&entity).Elem(), newMappingOptions(nil)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if expected := []StringUnderlyingType{"foo", "qux"}; !reflect.DeepEqual(entity.MultipleRelation, expected) {
+		t.Errorf("expected %v, got %v", expected, entity.MultipleRelation)
+	}
+	if expected := []StringUnderlyingType{"bar", "baz"}; !reflect.DeepEqual(entity.MultipleSelect, expected) {
+		t.Errorf("expected %v, got %v", expected, entity.MultipleSelect)
+	}
+}
+
+func TestDecodeNullablePointers(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	coll, err := testApp.Dao().FindCollectionByNameOrId("foo")
+	if err != nil {
+		t.Fatalf("could not find collection: %v", err)
+	}
+
+	text := "foo"
+	actual := EntityWithNullablePointers{Text: &text}
+	if err := Decode(models.NewRecord(coll), &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if expected := (EntityWithNullablePointers{}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected nil pointers for unset columns, got %+v", actual)
+	}
+
+	underlying, count, float, yes, selected, multiple := Foo, 3, 1.5, true, "a", []string{"b", "c"}
+	entity := EntityWithNullablePointers{
+		Id:             "i7iedw7au80qljq",
+		Text:           &text,
+		TextUnderlying: &underlying,
+		Int:            &count,
+		Float:          &float,
+		Bool:           &yes,
+		Object:         &Obj{Foo: 1, Bar: "bar"},
+		SingleSelect:   &selected,
+		MultipleSelect: &multiple,
+	}
+
+	record, err := Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual = EntityWithNullablePointers{}
+	if err := Decode(record, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, entity) {
+		t.Errorf("expected %+v, got %+v", entity, actual)
+	}
+
+	// number and bool columns are never null, their zero value is decoded as nil
+	var schemaErr *SchemaError
+	if err := Validate[EntityWithNullablePointers](testApp.Dao()); !errors.As(err, &schemaErr) || len(schemaErr.Problems) != 3 {
+		t.Errorf("expected the Int, Float and Bool fields to be reported, got %v", err)
+	}
+}
+
+func TestDecodeStoredNullablePointers(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	empty, zero, no := "", 0, false
+	dataset := []struct {
+		label  string
+		entity EntityWithNullablePointers
+	}{
+		{"nil pointers", EntityWithNullablePointers{}},
+		{"zero values", EntityWithNullablePointers{Text: &empty, Int: &zero, Bool: &no}},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			record, err := Encode(&data.entity, testApp.Dao(), Strict())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if err := testApp.Dao().SaveRecord(record); err != nil {
+				t.Fatalf("could not save record: %v", err)
+			}
+
+			stored, err := testApp.Dao().FindRecordById(record.Collection().Name, record.Id)
+			if err != nil {
+				t.Fatalf("could not find record: %v", err)
+			}
+
+			actual := EntityWithNullablePointers{}
+			if err := Decode(stored, &actual, Strict()); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if expected := (EntityWithNullablePointers{Id: record.Id}); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected nil pointers, got %+v", actual)
+			}
+		})
+	}
+}
+
+func TestDecodeDates(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithDates{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatalf("could not load location: %v", err)
+	}
+
+	local := time.Date(2023, 6, 1, 14, 30, 0, 0, paris)
+	dt, err := types.ParseDateTime(local)
+	if err != nil {
+		t.Fatalf("could not parse date: %v", err)
+	}
+
+	entity := EntityWithDates{Time: local, TimePtr: &local, DateTime: dt, DateTimePtr: &dt}
+	record, err := Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, column := range []string{"time", "time_ptr", "datetime", "datetime_ptr"} {
+		if actual := record.GetString(column); actual != "2023-06-01 12:30:00.000Z" {
+			t.Errorf("expected %s to be written in UTC, got %q", column, actual)
+		}
+	}
+
+	if err := testApp.Dao().SaveRecord(record); err != nil {
+		t.Fatalf("could not save record: %v", err)
+	}
+
+	dataset := []struct {
+		label            string
+		opts             []Option
+		expectedLocation *time.Location
+	}{
+		{"utc by default", nil, time.UTC},
+		{"in location", []Option{InLocation(paris)}, paris},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			actual := EntityWithDates{}
+			if err := Decode(record, &actual, append(data.opts, Strict())...); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !actual.Time.Equal(local) || actual.Time.Location() != data.expectedLocation {
+				t.Errorf("expected %v in %v, got %v", local, data.expectedLocation, actual.Time)
+			}
+			if actual.TimePtr == nil || !actual.TimePtr.Equal(local) || actual.TimePtr.Location() != data.expectedLocation {
+				t.Errorf("expected %v in %v, got %v", local, data.expectedLocation, actual.TimePtr)
+			}
+			if actual.Created.IsZero() || actual.Created.Location() != data.expectedLocation {
+				t.Errorf("expected created in %v, got %v", data.expectedLocation, actual.Created)
+			}
+			if !actual.DateTime.Time().Equal(local) || actual.DateTime.Time().Location() != time.UTC {
+				t.Errorf("expected %v in UTC, got %v", local, actual.DateTime)
+			}
+			if actual.DateTimePtr == nil || !actual.DateTimePtr.Time().Equal(local) {
+				t.Errorf("expected %v, got %v and %v", dt, actual.DateTime, actual.DateTimePtr)
+			}
+		})
+	}
+}
+
+func TestDecodeBlankDates(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithDates{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	record, err := Encode(&EntityWithDates{}, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual := record.GetString("time"); actual != "" {
+		t.Errorf("expected zero time to be written blank, got %q", actual)
+	}
+
+	now := time.Now()
+	actual := EntityWithDates{Time: now, TimePtr: &now}
+	if err := Decode(record, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if expected := (EntityWithDates{}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected zero dates and nil pointers, got %+v", actual)
+	}
+
+	if err := Validate[EntityWithDates](testApp.Dao()); err != nil {
+		t.Errorf("expected no validation error, got %v", err)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	r := recordExample
+
+	entity := EntityWithSchemaMismatches{}
+	err := Decode(r, &entity, Strict())
+
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) {
+		t.Fatalf("expected *MappingError, got %v", err)
+	}
+
+	if actualLen := len(mappingErr.Fields); actualLen != 2 {
+		t.Fatalf("expected 2 field errors, got %d: %v", actualLen, mappingErr)
+	}
+
+	expected := &FieldError{
+		Field:     "Text",
+		Column:    "text",
+		FieldType: schema.FieldTypeText,
+		GoType:    reflect.TypeOf(0),
+		Err:       mappingErr.Fields[0].Err,
+	}
+	if !reflect.DeepEqual(mappingErr.Fields[0], expected) {
+		t.Errorf("expected %v, got %v", expected, mappingErr.Fields[0])
+	}
+
+	if actual := mappingErr.Fields[1].Field; actual != "MultipleSelect" {
+		t.Errorf("expected MultipleSelect field error, got %s", actual)
+	}
+
+	if entity.Id != r.Id {
+		t.Errorf("expected the other fields to be decoded, got %v", entity)
+	}
+}
+
+func TestDecodeStrictSystemFields(t *testing.T) {
+	r := recordExample
+
+	entity := EntityWithSystemFieldsMismatches{}
+	err := Decode(r, &entity, Strict())
+
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) {
+		t.Fatalf("expected *MappingError, got %v", err)
+	}
+
+	dataset := []struct {
+		field             string
+		expectedFieldType string
+	}{
+		{"Created", schema.FieldTypeDate},
+		{"CollectionId", schema.FieldTypeText},
+	}
+
+	if actualLen := len(mappingErr.Fields); actualLen != len(dataset) {
+		t.Fatalf("expected %d field errors, got %d: %v", len(dataset), actualLen, mappingErr)
+	}
+
+	for i, data := range dataset {
+		if actual := mappingErr.Fields[i]; actual.Field != data.field || actual.FieldType != data.expectedFieldType {
+			t.Errorf("expected %s field error on a %s column, got %v", data.field, data.expectedFieldType, actual)
+		}
+	}
+
+	if entity.Id != r.Id {
+		t.Errorf("expected the other fields to be decoded, got %v", entity)
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	r := recordExample
+
+	fieldErrs := []*FieldError{}
+	handler := WithErrorHandler(func(err *FieldError) {
+		fieldErrs = append(fieldErrs, err)
+	})
+
+	entity := EntityWithSchemaMismatches{}
+	if err := Decode(r, &entity, handler); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if actualLen := len(fieldErrs); actualLen != 2 {
+		t.Errorf("expected 2 reported field errors, got %d", actualLen)
+	}
+}
+
+func TestDecodeStrictNestedRelations(t *testing.T) {
+	tag := models.NewRecord(Tag{}.Collection())
+	tag.SetId("tag2")
+	tag.Set("label", "qux")
+
+	r := models.NewRecord(Book{}.Collection())
+	r.SetId("book1")
+	r.Set("tags", `["tag1","tag2"]`)
+	r.SetExpand(map[string]any{"tags": []*models.Record{tag}})
+
+	entity := BookWithNumericLabelTags{}
+	err := Decode(r, &entity, Strict())
+
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) {
+		t.Fatalf("expected *MappingError, got %v", err)
+	}
+
+	if len(mappingErr.Fields) != 1 || mappingErr.Fields[0].Field != "Tags[1].Label" {
+		t.Errorf("expected Tags[1].Label field error, got %v", mappingErr)
+	}
+
+	if len(entity.Tags) != 2 || entity.Tags[1].Id != "tag2" {
+		t.Errorf("expected the related entities to be decoded, got %v", entity.Tags)
+	}
+}
@@ -3,28 +3,44 @@ package orm
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"reflect"
 	"time"
 
 	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
-func EncodeAll[T Entity](entities []*T, dao *daos.Dao) ([]*models.Record, error) {
+// EncodeAll returns new records of the collection of the entities holding their values, see Encode.
+// The collections are looked up once for all the entities.
+func EncodeAll[T Entity](entities []*T, dao *daos.Dao, opts ...Option) ([]*models.Record, error) {
+	if dao == nil {
+		return nil, fmt.Errorf("could not encode: dao is nil")
+	}
+
+	var zero T
+	coll, err := findCollection(dao, zero.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("could not get entity collection: %w", err)
+	}
+
+	related := newRelatedCollections(dao)
+	mappingOpts := newMappingOptions(opts)
 	records := make([]*models.Record, len(entities))
 	for i, e := range entities {
-		record, err := Encode(e, dao)
-		if err != nil {
+		r := models.NewRecord(coll)
+		if err := encodeInto(e, r, related, mappingOpts); err != nil {
 			return nil, fmt.Errorf("could not encode %d element: %w", i, err)
 		}
-		records[i] = record
+		records[i] = r
 	}
 	return records, nil
 }
 
-func Encode[T Entity](entity *T, dao *daos.Dao) (*models.Record, error) {
+// Encode returns a new record of the collection of entity holding its values. The fields which can't be encoded
+// are skipped and reported to the error handler, unless the Strict option is given, see MappingError.
+func Encode[T Entity](entity *T, dao *daos.Dao, opts ...Option) (*models.Record, error) {
 	if dao == nil {
 		return nil, fmt.Errorf("could not encode: dao is nil")
 	}
@@ -33,122 +49,296 @@ func Encode[T Entity](entity *T, dao *daos.Dao) (*models.Record, error) {
 		return nil, fmt.Errorf("could not encode nil entity")
 	}
 
-	coll, err := dao.FindCollectionByNameOrId((*entity).CollectionName())
+	coll, err := findCollection(dao, (*entity).CollectionName())
 	if err != nil {
 		return nil, fmt.Errorf("could not get entity collection: %w", err)
-	} else if coll == nil {
-		return nil, fmt.Errorf("could not get entity collection: collection is nil")
 	}
 
-	ps := reflect.ValueOf(entity)
-	s := ps.Elem()
+	r := models.NewRecord(coll)
+	if err := EncodeInto(entity, r, dao, opts...); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// EncodeInto sets the values of entity into record, an existing record of the collection of entity.
+// The state of record (e.g. IsNew and the expand data) and the columns not mapped by entity are kept.
+// The record is left untouched when the encoding fails.
+func EncodeInto[T Entity](entity *T, record *models.Record, dao *daos.Dao, opts ...Option) error {
+	if dao == nil {
+		return fmt.Errorf("could not encode: dao is nil")
+	}
+
+	return encodeInto(entity, record, newRelatedCollections(dao), newMappingOptions(opts))
+}
+
+// encodeInto is EncodeInto, checking the related collections with related.
+func encodeInto[T Entity](entity *T, record *models.Record, related *relatedCollections, opts *mappingOptions) error {
+	if entity == nil {
+		return fmt.Errorf("could not encode nil entity")
+	}
+
+	if record == nil || record.Collection() == nil {
+		return fmt.Errorf("could not encode into a record without collection")
+	}
 
+	coll := record.Collection()
+	if name := (*entity).CollectionName(); coll.Name != name && coll.Id != name {
+		return fmt.Errorf("could not encode entity of collection %q into a record of collection %q", name, coll.Name)
+	}
+
+	s := reflect.ValueOf(entity).Elem()
 	if s.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("entity given is not a structure")
+		return fmt.Errorf("entity given is not a structure")
 	}
 
-	r := models.NewRecord(coll)
+	return encodeStruct(s, record, related, opts)
+}
 
-	numField := reflect.TypeOf(entity).Elem().NumField()
-	for i := 0; i < numField; i++ {
-		field := reflect.TypeOf(entity).Elem().Field(i)
-		columnName := extractOrmNameFromTag(string(field.Tag))
+// encodeStruct sets the values of s into record, once all of them are encoded.
+func encodeStruct(s reflect.Value, record *models.Record, related *relatedCollections, opts *mappingOptions) error {
+	columns, mappingErr := encodeColumns(s, record.Collection().Schema, related)
+	if err := opts.handle(mappingErr.errOrNil()); err != nil {
+		return err
+	}
 
-		fieldType := fieldFromColumnName(coll.Schema, columnName)
-		if fieldType == nil {
+	// the changes are detected against the values the entity was decoded from, if given
+	base := record
+	if opts.baseline != nil {
+		base = opts.baseline
+	}
+
+	for _, c := range columns {
+		if opts.changedOnly && !hasChanged(base, c.fieldType, c.value) {
 			continue
 		}
+		record.Set(c.fieldType.Name, c.value)
+	}
+	return nil
+}
+
+// encodedColumn is the value of a column encoded from an entity field.
+type encodedColumn struct {
+	fieldType *schema.SchemaField
+	value     any
+}
 
-		entityField := reflect.ValueOf(entity).Elem().Field(i)
+// encodeColumns returns the values of the columns of collSchema mapped by the structure value s, and a
+// *MappingError listing the fields which could not be encoded. The related collections are not checked
+// when related is nil.
+func encodeColumns(s reflect.Value, collSchema schema.Schema, related *relatedCollections) ([]encodedColumn, *MappingError) {
+	mappingErr := &MappingError{Entity: s.Type().Name()}
+	var columns []encodedColumn
 
-		if isOmitable(string(field.Tag)) {
-			if entityField.IsZero() {
-				break
-			}
+	for _, fp := range planOf(s.Type()).fields {
+		if fp.readOnly {
+			continue
 		}
 
-		switch fieldType.Type {
-		case schema.FieldTypeText, schema.FieldTypeEmail, schema.FieldTypeUrl, schema.FieldTypeEditor:
-			if field.Type.Kind() != reflect.String {
-				break
-			}
-			r.Set(columnName, entityField.String())
-			break
+		fieldType := fieldFromColumnName(collSchema, fp.columnName)
+		if fieldType == nil {
+			continue
+		}
 
-		case schema.FieldTypeNumber:
-			switch entityField.Kind() {
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				r.Set(columnName, entityField.Int())
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				r.Set(columnName, entityField.Uint())
-			case reflect.Float32, reflect.Float64:
-				r.Set(columnName, entityField.Float())
+		// the columns of a nil inlined structure pointer are blanked
+		entityField, ok := fp.value(s)
+		if !ok {
+			if len(fp.inlinedPointers) > 0 && !fp.omitEmpty {
+				columns = append(columns, encodedColumn{fieldType: fieldType, value: nil})
 			}
-			break
+			continue
+		}
+
+		if fp.omitEmpty && entityField.IsZero() {
+			continue
+		}
 
-		case schema.FieldTypeBool:
-			if field.Type.Kind() != reflect.Bool {
-				break
+		if fieldType.Type == schema.FieldTypeRelation && !fp.custom && related != nil {
+			if err := related.check(fieldType, fp.typ); err != nil {
+				mappingErr.add(fp, fieldType.Type, err)
+				continue
 			}
-			r.Set(columnName, entityField.Bool())
-			break
+		}
+
+		value, err := fp.encode(fieldType, entityField)
+		if err != nil {
+			mappingErr.add(fp, fieldType.Type, err)
+			continue
+		}
 
-		case schema.FieldTypeDate:
-			_time, ok := entityField.Interface().(*time.Time)
-			if !ok || _time == nil {
-				break
+		// an empty id keeps the id of the record, e.g. the one generated for a new record
+		if fp.columnName == schema.FieldNameId && value == "" {
+			continue
+		}
+
+		columns = append(columns, encodedColumn{fieldType: fieldType, value: value})
+	}
+
+	return columns, mappingErr
+}
+
+// hasChanged reports whether setting value into the column of fieldType would change record.
+// An unset column holds the blank value of its type, and JSON columns are compared by their decoded value.
+func hasChanged(record *models.Record, fieldType *schema.SchemaField, value any) bool {
+	current := fieldType.PrepareValue(record.Get(fieldType.Name))
+	prepared := fieldType.PrepareValue(value)
+
+	if fieldType.Type == schema.FieldTypeJson {
+		if same, ok := sameJSON(prepared, current); ok {
+			return !same
+		}
+	}
+	return !reflect.DeepEqual(prepared, current)
+}
+
+// sameJSON reports whether a and b hold the same JSON value, whatever their formatting.
+// It returns false if any of them is not valid JSON.
+func sameJSON(a, b any) (bool, bool) {
+	var decoded [2]any
+	for i, v := range []any{a, b} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return false, false
+		}
+		if err := json.Unmarshal(data, &decoded[i]); err != nil {
+			return false, false
+		}
+	}
+	return reflect.DeepEqual(decoded[0], decoded[1]), true
+}
+
+// encodeValue is the default encodeFunc, converting entityField according to the PocketBase type of fieldType.
+func encodeValue(fieldType *schema.SchemaField, entityField reflect.Value) (any, error) {
+	// nil pointers are set to nil, which PocketBase prepares as the blank value of the column
+	if isNullablePointer(fieldType, entityField.Type()) {
+		if entityField.IsNil() {
+			return nil, nil
+		}
+		return encodeValue(fieldType, entityField.Elem())
+	}
+
+	switch fieldType.Type {
+	case schema.FieldTypeText, schema.FieldTypeEmail, schema.FieldTypeUrl, schema.FieldTypeEditor:
+		if text, ok, err := textOf(entityField); ok {
+			return text, err
+		}
+
+	case schema.FieldTypeNumber:
+		if number, ok, err := numberOf(entityField); ok {
+			if err != nil {
+				return nil, err
 			}
+			return number, checkNumberRange(fieldType, number)
+		}
 
-			r.Set(columnName, _time.String())
-			break
+	case schema.FieldTypeBool:
+		if entityField.Kind() == reflect.Bool {
+			return entityField.Bool(), nil
+		}
 
-		case schema.FieldTypeJson:
-			data, err := json.Marshal(entityField.Interface())
+	case schema.FieldTypeDate:
+		// dates are written in UTC, with the PocketBase layout
+		switch date := entityField.Interface().(type) {
+		case time.Time:
+			dt, err := types.ParseDateTime(date)
 			if err != nil {
-				log.Printf("could not marshal %v: %v", entityField.Interface(), err)
-				break
+				return nil, fmt.Errorf("could not encode date %v: %w", date, err)
 			}
+			return dt.String(), nil
+		case types.DateTime:
+			return date.String(), nil
+		}
 
-			r.Set(columnName, string(data))
-			break
+	case schema.FieldTypeJson:
+		// json.Marshal honors json.Marshaler, then encoding.TextMarshaler
+		data, err := json.Marshal(entityField.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal %v: %w", entityField.Interface(), err)
+		}
+		return string(data), nil
 
-		case schema.FieldTypeRelation:
-			if !(fieldType.Options.(*schema.RelationOptions)).IsMultiple() {
-				if field.Type.Kind() != reflect.String {
-					break
+	case schema.FieldTypeRelation:
+		options := fieldType.Options.(*schema.RelationOptions)
+		if !options.IsMultiple() {
+			if isRelated(entityField.Type()) {
+				ids := relatedEntityIds(entityField)
+				if len(ids) == 0 {
+					return "", nil
 				}
-				r.Set(columnName, entityField.String())
-				break
+				return ids[0], nil
 			}
 
-			data, err := json.Marshal(entityField.Interface())
+			if text, ok, err := textOf(entityField); ok {
+				return text, err
+			}
+			break
+		}
+
+		var ids []string
+		if isRelatedSlice(entityField.Type()) {
+			ids = relatedEntityIds(entityField)
+		} else if texts, ok, err := textsOf(entityField); ok {
 			if err != nil {
-				log.Printf("could not marshal %v: %v", entityField.Interface(), err)
-				break
+				return nil, err
 			}
+			ids = texts
+		} else {
+			break
+		}
 
-			r.Set(columnName, string(data))
+		if err := checkRelationCount(options, ids); err != nil {
+			return nil, err
+		}
+		return marshalTexts(ids)
+
+	case schema.FieldTypeFile:
+		names, ok := fileNames(entityField)
+		if !ok {
 			break
+		}
 
-		case schema.FieldTypeSelect:
-			if !(fieldType.Options.(*schema.SelectOptions)).IsMultiple() {
-				if field.Type.Kind() != reflect.String {
-					break
+		if !(fieldType.Options.(*schema.FileOptions)).IsMultiple() {
+			if len(names) == 0 {
+				return "", nil
+			}
+			return names[0], nil
+		}
+		return names, nil
+
+	case schema.FieldTypeSelect:
+		if !(fieldType.Options.(*schema.SelectOptions)).IsMultiple() {
+			if text, ok, err := textOf(entityField); ok {
+				if err != nil {
+					return nil, err
 				}
-				r.Set(columnName, entityField.String())
-				break
+				return text, checkSelectValues(fieldType, text)
 			}
+			break
+		}
 
-			data, err := json.Marshal(entityField.Interface())
+		if texts, ok, err := textsOf(entityField); ok {
 			if err != nil {
-				log.Printf("could not marshal %v: %v", entityField.Interface(), err)
-				break
+				return nil, err
 			}
-
-			r.Set(columnName, string(data))
-			break
+			if err := checkSelectValues(fieldType, texts...); err != nil {
+				return nil, err
+			}
+			return marshalTexts(texts)
 		}
+
+	default:
+		return nil, fmt.Errorf("unsupported field type %q", fieldType.Type)
 	}
 
-	return r, nil
+	return nil, kindError(fieldType.Type, entityField.Type())
+}
+
+// marshalTexts returns the JSON array of texts, the value of a multiple select or relation column.
+func marshalTexts(texts []string) (string, error) {
+	data, err := json.Marshal(texts)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal %v: %w", texts, err)
+	}
+	return string(data), nil
 }
@@ -1,25 +1,40 @@
 package orm
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
-func setupEncodeTests() (testApp *tests.TestApp, err error) {
-	testApp, err = tests.NewTestApp()
+// setupTestApp returns a new test app holding the collections colls, saved in order.
+func setupTestApp(colls ...*models.Collection) (*tests.TestApp, error) {
+	testApp, err := tests.NewTestApp()
 	if err != nil {
 		return nil, fmt.Errorf("could not create testApp: %w", err)
 	}
 
-	if err := testApp.Dao().SaveCollection(EntityWithAllPBTypes{}.Collection()); err != nil {
-		return nil, fmt.Errorf("could not save collection: %w", err)
+	for _, coll := range colls {
+		if err := testApp.Dao().SaveCollection(coll); err != nil {
+			testApp.Cleanup()
+			return nil, fmt.Errorf("could not save collection: %w", err)
+		}
 	}
 
-	return
+	return testApp, nil
+}
+
+func setupEncodeTests() (*tests.TestApp, error) {
+	return setupTestApp(EntityWithAllPBTypes{}.Collection())
 }
 
 func TestEncode(t *testing.T) {
@@ -68,6 +83,33 @@ func TestEncodeWithZeroValue(t *testing.T) {
 	}
 }
 
+func TestEncodeOmitEmptyBeforeOtherFields(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	// the empty omitempty fields are skipped, not the fields declared after them
+	entity := EntityWithAllPBTypes{NumberInt: 7, Email: "foo@example.com", MultipleSelect: []string{"a"}}
+	actual, err := Encode(&entity, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := models.NewRecord(EntityWithAllPBTypes{}.Collection())
+	expected.Set("number_int", 7)
+	expected.Set("email", "foo@example.com")
+	expected.Set("multiple_select", []string{"a"})
+
+	actualBusinessFields := RecordsColumnValueMap(actual)
+	expectedBusinessFields := RecordsColumnValueMap(expected)
+
+	if !reflect.DeepEqual(actualBusinessFields, expectedBusinessFields) {
+		t.Errorf("expected %v, got %v", expectedBusinessFields, actualBusinessFields)
+	}
+}
+
 func TestEncodeAll(t *testing.T) {
 	testApp, err := setupEncodeTests()
 	if err != nil {
@@ -97,3 +139,403 @@ func TestEncodeAll(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeRelations(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	for _, coll := range []*models.Collection{Author{}.Collection(), Tag{}.Collection(), Book{}.Collection()} {
+		if err := testApp.Dao().SaveCollection(coll); err != nil {
+			t.Fatalf("could not save collection: %v", err)
+		}
+	}
+
+	entity := Book{
+		Id:     "book1",
+		Title:  "foo",
+		Author: &Author{Id: "author1", Name: "bar"},
+		Tags:   []*Tag{{Id: "tag1", Label: "qux"}, nil, {Id: "tag2"}},
+	}
+	actual, err := Encode(&entity, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := models.NewRecord(Book{}.Collection())
+	expected.SetId("book1")
+	expected.Set("title", "foo")
+	expected.Set("author", "author1")
+	expected.Set("tags", `["tag1","tag2"]`)
+
+	actualBusinessFields := RecordsColumnValueMap(actual)
+	expectedBusinessFields := RecordsColumnValueMap(expected)
+
+	if !reflect.DeepEqual(actualBusinessFields, expectedBusinessFields) {
+		t.Errorf("expected %v, got %v", expectedBusinessFields, actualBusinessFields)
+	}
+}
+
+func TestEncodeAllLooksUpCollectionsOnce(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	for _, coll := range []*models.Collection{Author{}.Collection(), Tag{}.Collection(), Book{}.Collection()} {
+		if err := testApp.Dao().SaveCollection(coll); err != nil {
+			t.Fatalf("could not save collection: %v", err)
+		}
+	}
+
+	collectionQueries := 0
+	db := testApp.Dao().ConcurrentDB().(*dbx.DB)
+	db.QueryLogFunc = func(_ context.Context, _ time.Duration, query string, _ *sql.Rows, _ error) {
+		if strings.Contains(query, "_collections") {
+			collectionQueries++
+		}
+	}
+	defer func() { db.QueryLogFunc = nil }()
+
+	entities := make([]*Book, 100)
+	for i := range entities {
+		entities[i] = &Book{
+			Title:  "foo",
+			Author: &Author{Id: "author1"},
+			Tags:   []*Tag{{Id: "tag1"}, {Id: "tag2"}},
+		}
+	}
+
+	if _, err := EncodeAll(entities, testApp.Dao()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the collections of the books, the authors and the tags
+	if collectionQueries != 3 {
+		t.Errorf("expected 3 collection queries, got %d", collectionQueries)
+	}
+}
+
+func TestEncodeNeverWritesSystemFields(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithSystemFields{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	updated := types.NowDateTime()
+	entity := EntityWithSystemFields{
+		Title:    "foo",
+		Created:  time.Now(),
+		Updated:  &updated,
+		CollId:   "bar",
+		CollName: "qux",
+	}
+	actual, err := Encode(&entity, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !actual.Created.IsZero() || !actual.Updated.IsZero() {
+		t.Errorf("expected zero created and updated, got %v and %v", actual.Created, actual.Updated)
+	}
+
+	if actual.Collection().Name != "systems" {
+		t.Errorf("expected collection %q, got %q", "systems", actual.Collection().Name)
+	}
+}
+
+func TestEncodeNilPointers(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	record, err := Encode(&EntityWithNullablePointers{}, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	dataset := []struct {
+		label    string
+		column   string
+		expected any
+	}{
+		{"text", "text", ""},
+		{"number", "number_int", 0.0},
+		{"bool", "bool", false},
+		{"single select", "single_select", ""},
+		{"multiple select", "multiple_select", "[]"},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if actual := record.Get(data.column); fmt.Sprint(actual) != fmt.Sprint(data.expected) {
+				t.Errorf("expected blank value %v, got %v", data.expected, actual)
+			}
+		})
+	}
+
+	if err := testApp.Dao().SaveRecord(record); err != nil {
+		t.Errorf("expected blank values to be saved, got %v", err)
+	}
+}
+
+func TestEncodeInlineStructs(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithInlineStructs{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	entity := EntityWithInlineStructs{
+		Name:    "foo",
+		Address: Address{Street: "1 main street", City: "Paris", Geo: Geo{Lat: 48.85, Lng: 2.35}},
+	}
+	record, err := Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if street, lat := record.GetString("addr_street"), record.GetFloat("addr_geo_lat"); street != "1 main street" || lat != 48.85 {
+		t.Errorf("expected address columns to be set, got %q and %v", street, lat)
+	}
+
+	if city, lat := record.Get("billing_city"), record.Get("billing_geo_lat"); city != "" || lat != 0.0 {
+		t.Errorf("expected nil billing address columns to be blank, got %v and %v", city, lat)
+	}
+
+	entity.Billing = &Address{City: "Lyon"}
+	record, err = Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual := EntityWithInlineStructs{}
+	if err := Decode(record, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, entity) {
+		t.Errorf("expected %v, got %v", entity, actual)
+	}
+
+	if err := Validate[EntityWithInlineStructs](testApp.Dao()); err != nil {
+		t.Errorf("expected no validation error, got %v", err)
+	}
+}
+
+func TestEncodeStrict(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity := EntityWithSchemaMismatches{Text: 1, MultipleSelect: "foo"}
+	_, err = Encode(&entity, testApp.Dao(), Strict())
+
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) {
+		t.Fatalf("expected *MappingError, got %v", err)
+	}
+
+	actual := []string{}
+	for _, fieldErr := range mappingErr.Fields {
+		actual = append(actual, fieldErr.Field)
+	}
+	if expected := []string{"Text", "MultipleSelect"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected field errors on %v, got %v", expected, actual)
+	}
+}
+
+func TestEncodeLenient(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	fieldErrs := []*FieldError{}
+	handler := WithErrorHandler(func(err *FieldError) {
+		fieldErrs = append(fieldErrs, err)
+	})
+
+	entity := EntityWithSchemaMismatches{Id: "i7iedw7au80qljq", Text: 1, Bool: true}
+	record, err := Encode(&entity, testApp.Dao(), handler)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(fieldErrs) != 2 {
+		t.Errorf("expected 2 reported field errors, got %v", fieldErrs)
+	}
+
+	if record.Id != entity.Id || !record.GetBool("bool") {
+		t.Errorf("expected the other fields to be encoded, got %v", record)
+	}
+}
+
+func TestEncodeInto(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity := entityExample
+	record, err := Encode(&entity, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := testApp.Dao().SaveRecord(record); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stored, err := testApp.Dao().FindRecordById("foo", entity.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stored.SetExpand(map[string]any{"single_relation": models.NewRecord(stored.Collection())})
+
+	partial := PartialEntity{Id: entity.Id, Text: "updated", NumberInt: 3}
+	if err := EncodeInto(&partial, stored, testApp.Dao(), Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if stored.IsNew() {
+		t.Errorf("expected record to stay not new")
+	}
+
+	if _, ok := stored.Expand()["single_relation"]; !ok {
+		t.Errorf("expected expand data to be kept, got %v", stored.Expand())
+	}
+
+	dataset := []struct {
+		label    string
+		column   string
+		expected any
+	}{
+		{"mapped text", "text", "updated"},
+		{"mapped number", "number_int", 3.0},
+		{"unmapped email", "email", entity.Email},
+		{"unmapped bool", "bool", entity.Bool},
+		{"unmapped number", "number_float64", entity.NumberFloat64},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if actual := stored.Get(data.column); actual != data.expected {
+				t.Errorf("expected %v, got %v", data.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEncodeIntoKeepsRecordOnError(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	record, err := Encode(&PartialEntity{Text: "foo"}, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = EncodeInto(&EntityWithSchemaMismatches{Renamed: "bar", Bool: true}, record, testApp.Dao(), Strict())
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if actual := record.GetBool("bool"); actual {
+		t.Errorf("expected record to be left untouched, got bool %v", actual)
+	}
+}
+
+func TestEncodeIntoOtherCollection(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	record, err := Encode(&PartialEntity{}, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := EncodeInto(&EntityWithDates{}, record, testApp.Dao()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if err := EncodeInto(&PartialEntity{}, nil, testApp.Dao()); err == nil {
+		t.Errorf("expected error on nil record, got nil")
+	}
+}
+
+func TestEncodeIntoChangedOnly(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	record, err := Encode(&PartialEntity{Id: "i7iedw7au80qljq", Text: "foo", NumberInt: 1}, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	record.Set("json_object", `{"foo": 1, "bar": "x"}`)
+
+	dataset := []struct {
+		label    string
+		column   string
+		value    any
+		expected bool
+	}{
+		{"same id", "id", "i7iedw7au80qljq", false},
+		{"other id", "id", "yadrlzlch3tjfd0", true},
+		{"same text", "text", "foo", false},
+		{"other text", "text", "bar", true},
+		{"same number", "number_int", 1.0, false},
+		{"other number", "number_int", 2.0, true},
+		{"blank bool", "bool", false, false},
+		{"set bool", "bool", true, true},
+		{"reformatted json", "json_object", `{"foo":1,"bar":"x"}`, false},
+		{"other json", "json_object", `{"foo":2,"bar":"x"}`, true},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			fieldType := fieldFromColumnName(record.Collection().Schema, data.column)
+			if actual := hasChanged(record, fieldType, data.value); actual != data.expected {
+				t.Errorf("expected changed %v, got %v", data.expected, actual)
+			}
+		})
+	}
+
+	partial := PartialEntity{Id: "i7iedw7au80qljq", Text: "foo", NumberInt: 2}
+	if err := EncodeInto(&partial, record, testApp.Dao(), ChangedOnly()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual := record.GetString("text"); actual != "foo" {
+		t.Errorf("expected text foo, got %q", actual)
+	}
+	if actual := record.GetInt("number_int"); actual != 2 {
+		t.Errorf("expected changed number 2, got %d", actual)
+	}
+}
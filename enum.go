@@ -0,0 +1,97 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// Enum is implemented by the select value types listing their own values, such as the enums generated
+// by Generate. Validate reports the values of an Enum which differ from the values of its select column.
+type Enum interface {
+	EnumValues() []string
+}
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// SelectValueError is returned by Encode and Decode when a value is not one of the values of its select column.
+type SelectValueError struct {
+	Value  string
+	Values []string
+}
+
+func (e *SelectValueError) Error() string {
+	return fmt.Sprintf("%q is not one of the select values %q", e.Value, e.Values)
+}
+
+// checkSelectValues returns a *SelectValueError if one of values is not one of the values of the select
+// column fieldType. Empty values are blank values, always allowed.
+func checkSelectValues(fieldType *schema.SchemaField, values ...string) error {
+	options, ok := fieldType.Options.(*schema.SelectOptions)
+	if !ok || len(options.Values) == 0 {
+		return nil
+	}
+
+	for _, value := range values {
+		if value != "" && !containsString(options.Values, value) {
+			return &SelectValueError{Value: value, Values: options.Values}
+		}
+	}
+	return nil
+}
+
+// enumValues returns the values listed by t, an Enum type or a slice of or a pointer to an Enum type.
+// It returns false if t is not such a type.
+func enumValues(t reflect.Type) ([]string, bool) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Implements(enumType):
+		return reflect.Zero(t).Interface().(Enum).EnumValues(), true
+	case reflect.PointerTo(t).Implements(enumType):
+		return reflect.New(t).Interface().(Enum).EnumValues(), true
+	}
+	return nil, false
+}
+
+// checkEnumValues returns an error if t is an Enum type whose values differ from the values of the select
+// column fieldType.
+func checkEnumValues(fieldType *schema.SchemaField, t reflect.Type) error {
+	values, ok := enumValues(t)
+	if !ok {
+		return nil
+	}
+
+	columnValues := fieldType.Options.(*schema.SelectOptions).Values
+
+	drift := []string{}
+	for _, value := range values {
+		if !containsString(columnValues, value) {
+			drift = append(drift, fmt.Sprintf("%q is not a value of the column", value))
+		}
+	}
+	for _, value := range columnValues {
+		if !containsString(values, value) {
+			drift = append(drift, fmt.Sprintf("%q is missing", value))
+		}
+	}
+
+	if len(drift) > 0 {
+		return fmt.Errorf("%s values differ from the select column %q: %s", t, fieldType.Name, strings.Join(drift, ", "))
+	}
+	return nil
+}
+
+// containsString reports whether value is one of values.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
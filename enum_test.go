@@ -0,0 +1,114 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func TestEnums(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithEnums{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity := EntityWithEnums{Status: StatusPublished, Labels: []Label{"a", "c"}}
+	record, err := Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual := EntityWithEnums{}
+	if err := Decode(record, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, entity) {
+		t.Errorf("expected %v, got %v", entity, actual)
+	}
+}
+
+func TestEnumsUnknownValues(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithEnums{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dataset := []struct {
+		label         string
+		entity        EntityWithEnums
+		expectedField string
+		expectedValue string
+	}{
+		{"single select", EntityWithEnums{Status: "deleted"}, "Status", "deleted"},
+		{"multiple select", EntityWithEnums{Labels: []Label{"a", "z"}}, "Labels", "z"},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			_, err := Encode(&data.entity, testApp.Dao(), Strict())
+
+			var valueErr *SelectValueError
+			if !errors.As(err, &valueErr) {
+				t.Fatalf("expected *SelectValueError, got %v", err)
+			}
+			if valueErr.Value != data.expectedValue {
+				t.Errorf("expected unknown value %q, got %q", data.expectedValue, valueErr.Value)
+			}
+
+			var mappingErr *MappingError
+			if !errors.As(err, &mappingErr) || mappingErr.Fields[0].Field != data.expectedField {
+				t.Errorf("expected %s field error, got %v", data.expectedField, err)
+			}
+		})
+	}
+
+	record, err := Encode(&EntityWithEnums{}, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	record.Set("status", "deleted")
+
+	var valueErr *SelectValueError
+	if err := Decode(record, &EntityWithEnums{}, Strict()); !errors.As(err, &valueErr) {
+		t.Errorf("expected *SelectValueError, got %v", err)
+	}
+}
+
+func TestValidateEnums(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithEnums{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	var schemaErr *SchemaError
+	if err := Validate[EntityWithEnums](testApp.Dao()); !errors.As(err, &schemaErr) {
+		t.Fatalf("expected *SchemaError, got %v", err)
+	}
+
+	if len(schemaErr.Problems) != 1 || !strings.Contains(schemaErr.Problems[0], `"archived" is missing`) {
+		t.Errorf("expected archived value to be reported missing, got %v", schemaErr.Problems)
+	}
+}
+
+func TestBuildCollectionsWithEnums(t *testing.T) {
+	colls, err := BuildCollections(nil, EntityWithEnums{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	status := colls[0].Schema.GetFieldByName("status")
+	if status == nil || status.Type != schema.FieldTypeSelect {
+		t.Fatalf("expected status select field, got %v", status)
+	}
+
+	if options := status.Options.(*schema.SelectOptions); !reflect.DeepEqual(options.Values, StatusDraft.EnumValues()) || options.MaxSelect != 1 {
+		t.Errorf("expected the enum values, got %v", options)
+	}
+}
@@ -0,0 +1,101 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldError describes why a structure field could not be mapped onto its column.
+type FieldError struct {
+	Field     string
+	Column    string
+	FieldType string
+	GoType    reflect.Type
+	Err       error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s (%s) on %s column %q: %v", e.Field, e.GoType, e.FieldType, e.Column, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// MappingError is returned in strict mode by Encode and Decode, listing every field of the entity which
+// could not be mapped. The fields of the related entities are listed with their path, e.g. "Author.Name".
+type MappingError struct {
+	Entity string
+	Fields []*FieldError
+}
+
+func (e *MappingError) Error() string {
+	causes := make([]string, len(e.Fields))
+	for i, fieldErr := range e.Fields {
+		causes[i] = fieldErr.Error()
+	}
+	return fmt.Sprintf("could not map %d field(s) of %s: %s", len(e.Fields), e.Entity, strings.Join(causes, "; "))
+}
+
+func (e *MappingError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, fieldErr := range e.Fields {
+		errs[i] = fieldErr
+	}
+	return errs
+}
+
+// add records err, returned by the encoding or decoding of the field fp on the column of type fieldType.
+// It returns false if err is a fatalError, which must abort the mapping whatever the mode.
+func (e *MappingError) add(fp *fieldPlan, fieldType string, err error) bool {
+	switch err := err.(type) {
+	case *fatalError:
+		return false
+	case *MappingError:
+		for _, nested := range err.Fields {
+			nestedErr := *nested
+			nestedErr.Field = fp.name + "." + nested.Field
+			if strings.HasPrefix(nested.Field, "[") {
+				nestedErr.Field = fp.name + nested.Field
+			}
+			e.Fields = append(e.Fields, &nestedErr)
+		}
+	default:
+		e.Fields = append(e.Fields, &FieldError{
+			Field:     fp.name,
+			Column:    fp.columnName,
+			FieldType: fieldType,
+			GoType:    fp.typ,
+			Err:       err,
+		})
+	}
+	return true
+}
+
+// errOrNil returns e if it holds at least one field error, nil otherwise.
+func (e *MappingError) errOrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// fatalError is an error aborting the mapping, even in lenient mode (e.g. an expanded record
+// not belonging to the collection of the related entity).
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// kindError returns the error of a field of type t which can't hold a value of the column type fieldType.
+func kindError(fieldType string, t reflect.Type) error {
+	return fmt.Errorf("%s can't hold a %s value", t, fieldType)
+}
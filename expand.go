@@ -0,0 +1,149 @@
+package orm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/resolvers"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// expandRecords loads the relations described by paths (e.g. "author", "tags.owner") into the expand data of records.
+//
+// Every relation level is loaded with a single query for all records, whatever their number.
+func expandRecords(dao *daos.Dao, records []*models.Record, paths []string) error {
+	return expandRecordsWith(dao, records, paths, fetchRelated(dao))
+}
+
+// expandRecordsWith is expandRecords loading the related records with fetch.
+func expandRecordsWith(dao *daos.Dao, records []*models.Record, paths []string, fetch daos.ExpandFetchFunc) error {
+	if len(paths) == 0 || len(records) == 0 {
+		return nil
+	}
+
+	failed := dao.ExpandRecords(records, paths, fetch)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	failedPaths := make([]string, 0, len(failed))
+	for path := range failed {
+		failedPaths = append(failedPaths, path)
+	}
+	sort.Strings(failedPaths)
+
+	return fmt.Errorf("could not load relation %q: %w", failedPaths[0], failed[failedPaths[0]])
+}
+
+// fetchRelated returns the daos.ExpandFetchFunc loading the related records without any access rule.
+func fetchRelated(dao *daos.Dao) daos.ExpandFetchFunc {
+	return func(relCollection *models.Collection, relIds []string) ([]*models.Record, error) {
+		records, err := dao.FindRecordsByIds(relCollection.Id, relIds)
+		if err != nil {
+			return nil, err
+		}
+		return records, showEmails(dao, records, nil)
+	}
+}
+
+// fetchViewable returns the daos.ExpandFetchFunc loading the related records the request described by info
+// can view, according to the ViewRule of their collection as the PocketBase records API does.
+// The relations which can't be viewed are left unexpanded.
+func fetchViewable(dao *daos.Dao, info *models.RequestData) daos.ExpandFetchFunc {
+	return func(relCollection *models.Collection, relIds []string) ([]*models.Record, error) {
+		if info.Admin == nil && relCollection.ViewRule == nil {
+			return nil, nil
+		}
+
+		records, err := dao.FindRecordsByIds(relCollection.Id, relIds, func(q *dbx.SelectQuery) error {
+			if info.Admin != nil || *relCollection.ViewRule == "" {
+				return nil
+			}
+
+			resolver := resolvers.NewRecordFieldResolver(dao, relCollection, info, true)
+			expr, err := search.FilterData(*relCollection.ViewRule).BuildExpr(resolver)
+			if err != nil {
+				return fmt.Errorf("could not build view rule of collection %q: %w", relCollection.Name, err)
+			}
+			resolver.UpdateQuery(q)
+			q.AndWhere(expr)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return records, showEmails(dao, records, info)
+	}
+}
+
+// showEmails makes the JSON representation of records, auth records of the same collection, hold their email
+// when the request described by info can see it, as the PocketBase records API does: for admins, for the
+// owner of the record and for the records matching the ManageRule of the collection. A nil info shows
+// every email.
+func showEmails(dao *daos.Dao, records []*models.Record, info *models.RequestData) error {
+	if len(records) == 0 || !records[0].Collection().IsAuth() {
+		return nil
+	}
+
+	if info == nil || info.Admin != nil {
+		for _, record := range records {
+			record.IgnoreEmailVisibility(true)
+		}
+		return nil
+	}
+
+	coll := records[0].Collection()
+	byId := make(map[string]*models.Record, len(records))
+	ids := make([]any, len(records))
+	for i, record := range records {
+		byId[record.Id] = record
+		ids[i] = record.Id
+	}
+
+	if info.AuthRecord != nil && byId[info.AuthRecord.Id] != nil {
+		byId[info.AuthRecord.Id].IgnoreEmailVisibility(true)
+	}
+
+	manageRule := coll.AuthOptions().ManageRule
+	if manageRule == nil || *manageRule == "" {
+		return nil
+	}
+
+	idColumn := dao.DB().QuoteSimpleColumnName(coll.Name) + ".id"
+	query := dao.RecordQuery(coll).Select(idColumn).AndWhere(dbx.In(idColumn, ids...))
+
+	resolver := resolvers.NewRecordFieldResolver(dao, coll, info, true)
+	expr, err := search.FilterData(*manageRule).BuildExpr(resolver)
+	if err != nil {
+		return fmt.Errorf("could not build manage rule of collection %q: %w", coll.Name, err)
+	}
+	resolver.UpdateQuery(query)
+	query.AndWhere(expr)
+
+	managedIds := []string{}
+	if err := query.Column(&managedIds); err != nil {
+		return fmt.Errorf("could not find managed records: %w", err)
+	}
+
+	for _, id := range managedIds {
+		byId[id].IgnoreEmailVisibility(true)
+	}
+	return nil
+}
+
+// decodeRecords loads the relations described by paths into records, then decodes them into entities of T.
+func decodeRecords[T Entity](dao *daos.Dao, records []*models.Record, paths []string, opts ...Option) ([]*T, error) {
+	if err := expandRecords(dao, records, paths); err != nil {
+		return nil, err
+	}
+
+	entities := make([]*T, len(records))
+	if err := DecodeAll(records, entities, opts...); err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
@@ -0,0 +1,235 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func setupExpandTests() (testApp *tests.TestApp, err error) {
+	testApp, err = setupTestApp(Author{}.Collection(), Tag{}.Collection(), Book{}.Collection())
+	if err != nil {
+		return nil, err
+	}
+
+	authors := NewRepository[Author](testApp.Dao())
+	tags := NewRepository[Tag](testApp.Dao())
+	books := NewRepository[Book](testApp.Dao())
+
+	for _, author := range []*Author{{Id: "author1", Name: "foo"}, {Id: "author2", Name: "bar"}} {
+		if err := authors.Save(author); err != nil {
+			testApp.Cleanup()
+			return nil, fmt.Errorf("could not save author: %w", err)
+		}
+	}
+
+	for _, tag := range []*Tag{{Id: "tag1", Label: "qux", Owner: &Author{Id: "author2"}}, {Id: "tag2", Label: "quux"}} {
+		if err := tags.Save(tag); err != nil {
+			testApp.Cleanup()
+			return nil, fmt.Errorf("could not save tag: %w", err)
+		}
+	}
+
+	for i := 1; i <= 3; i++ {
+		book := &Book{
+			Id:     fmt.Sprintf("book%d", i),
+			Title:  fmt.Sprintf("title%d", i),
+			Author: &Author{Id: "author1"},
+			Tags:   []*Tag{{Id: "tag1"}, {Id: "tag2"}},
+		}
+		if err := books.Save(book); err != nil {
+			testApp.Cleanup()
+			return nil, fmt.Errorf("could not save book: %w", err)
+		}
+	}
+
+	return
+}
+
+func TestRepositoryWith(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	books, err := NewRepository[Book](testApp.Dao()).With("author", "tags.owner").FindAll()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actualLen := len(books); actualLen != 3 {
+		t.Fatalf("expected 3 elements, got %d", actualLen)
+	}
+
+	for _, book := range books {
+		expectedAuthor := &Author{Id: "author1", Name: "foo"}
+		if !reflect.DeepEqual(book.Author, expectedAuthor) {
+			t.Errorf("expected %v, got %v", expectedAuthor, book.Author)
+		}
+
+		expectedTags := []*Tag{
+			{Id: "tag1", Label: "qux", Owner: &Author{Id: "author2", Name: "bar"}},
+			{Id: "tag2", Label: "quux"},
+		}
+		if !reflect.DeepEqual(book.Tags, expectedTags) {
+			t.Errorf("expected %v, got %v", expectedTags, book.Tags)
+		}
+	}
+}
+
+func TestRepositoryWithThenSave(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	book, err := NewRepository[Book](testApp.Dao()).With("author", "tags.owner").FindById("book1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	book.Title = "updated"
+	if err := NewRepository[Book](testApp.Dao()).Save(book); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expectedAuthor := &Author{Id: "author1", Name: "foo"}
+	if !reflect.DeepEqual(book.Author, expectedAuthor) {
+		t.Errorf("expected loaded author %v to be kept, got %v", expectedAuthor, book.Author)
+	}
+
+	expectedTags := []*Tag{
+		{Id: "tag1", Label: "qux", Owner: &Author{Id: "author2", Name: "bar"}},
+		{Id: "tag2", Label: "quux"},
+	}
+	if !reflect.DeepEqual(book.Tags, expectedTags) {
+		t.Errorf("expected loaded tags %v to be kept, got %v", expectedTags, book.Tags)
+	}
+
+	refs, err := NewRepository[BookWithRefs](testApp.Dao()).With("author", "tags").FindById("book1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	refs.Tags = refs.Tags[1:]
+	if err := NewRepository[BookWithRefs](testApp.Dao()).Save(refs); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if refs.Author.Entity == nil || refs.Author.Entity.Name != "foo" {
+		t.Errorf("expected loaded author reference to be kept, got %v", refs.Author.Entity)
+	}
+	if len(refs.Tags) != 1 || refs.Tags[0].Entity == nil || refs.Tags[0].Entity.Label != "quux" {
+		t.Errorf("expected loaded tag references to be kept, got %v", refs.Tags)
+	}
+}
+
+func TestDecodeReplacesOtherRelatedEntity(t *testing.T) {
+	record := models.NewRecord(Book{}.Collection())
+	record.Set("author", "author2")
+
+	book := Book{Author: &Author{Id: "author1", Name: "foo"}}
+	if err := Decode(record, &book); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := &Author{Id: "author2"}
+	if !reflect.DeepEqual(book.Author, expected) {
+		t.Errorf("expected %v, got %v", expected, book.Author)
+	}
+}
+
+func TestRepositoryWithRunsOneQueryPerRelation(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	repository := NewRepository[Book](testApp.Dao())
+
+	// warm up the collections lookups
+	if _, err := repository.With("author", "tags.owner").FindAll(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	recordQueries := 0
+	db := testApp.Dao().ConcurrentDB().(*dbx.DB)
+	db.QueryLogFunc = func(_ context.Context, _ time.Duration, query string, _ *sql.Rows, _ error) {
+		if !strings.Contains(query, "_collections") {
+			recordQueries++
+		}
+	}
+	defer func() { db.QueryLogFunc = nil }()
+
+	if _, err := repository.With("author", "tags.owner").FindAll(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// books, authors, tags and tags owners
+	if recordQueries != 4 {
+		t.Errorf("expected 4 record queries, got %d", recordQueries)
+	}
+}
+
+func TestQueryWith(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	book, err := Query[Book](testApp.Dao()).Where("title", "=", "title2").With("author").One()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := &Author{Id: "author1", Name: "foo"}
+	if !reflect.DeepEqual(book.Author, expected) {
+		t.Errorf("expected %v, got %v", expected, book.Author)
+	}
+}
+
+func TestParsePageWithExpand(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	page, err := ParsePage[Book](testApp.Dao(), asAdmin, "perPage=1&sort=title&expand=tags")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actualLen := len(page.Items); actualLen != 1 {
+		t.Fatalf("expected 1 element, got %d", actualLen)
+	}
+
+	expected := []*Tag{{Id: "tag1", Label: "qux", Owner: &Author{Id: "author2"}}, {Id: "tag2", Label: "quux"}}
+	if !reflect.DeepEqual(page.Items[0].Tags, expected) {
+		t.Errorf("expected %v, got %v", expected, page.Items[0].Tags)
+	}
+}
+
+func TestWithUnknownRelation(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if _, err := NewRepository[Book](testApp.Dao()).With("title").FindAll(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,291 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/forms/validators"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+var fileType = reflect.TypeOf(File{})
+
+// File is the value of a PocketBase file field: the stored filename, optionally
+// replaced by a pending upload that is written to the storage when the entity is saved.
+type File struct {
+	Name   string
+	Upload *filesystem.File
+}
+
+// NewFile returns a File pending the upload of the given file.
+func NewFile(upload *filesystem.File) File {
+	return File{Upload: upload}
+}
+
+// Filename returns the name the file is (or will be once uploaded) stored with.
+func (f File) Filename() string {
+	if f.Upload != nil {
+		return f.Upload.Name
+	}
+	return f.Name
+}
+
+// MarshalJSON implements the [json.Marshaler] interface, a File is represented by its filename.
+func (f File) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Filename())
+}
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface.
+func (f *File) UnmarshalJSON(data []byte) error {
+	f.Upload = nil
+	return json.Unmarshal(data, &f.Name)
+}
+
+// FileURL returns the URL serving the file filename of entity, appURL being the PocketBase
+// application URL (e.g. app.Settings().Meta.AppUrl).
+func FileURL[T Entity](appURL string, entity *T, filename string) string {
+	if entity == nil || filename == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(appURL, "/") + "/api/files/" +
+		url.PathEscape((*entity).CollectionName()) + "/" +
+		url.PathEscape(entityId(reflect.ValueOf(entity).Elem())) + "/" +
+		url.PathEscape(filename)
+}
+
+// ThumbURL returns the URL serving the thumbnail of the file filename of entity,
+// size being one of the thumb sizes of the file field (e.g. "100x100").
+func ThumbURL[T Entity](appURL string, entity *T, filename string, size string) string {
+	fileURL := FileURL(appURL, entity, filename)
+	if fileURL == "" {
+		return ""
+	}
+
+	return fileURL + "?thumb=" + url.QueryEscape(size)
+}
+
+// fileNames returns the filenames held by entityField, which can be a string,
+// a slice of strings, a File or a slice of Files. Empty filenames are skipped.
+func fileNames(entityField reflect.Value) ([]string, bool) {
+	names := []string{}
+
+	switch {
+	case entityField.Kind() == reflect.String:
+		names = append(names, entityField.String())
+	case entityField.Type() == fileType:
+		names = append(names, entityField.Interface().(File).Filename())
+	case entityField.Kind() == reflect.Slice && entityField.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < entityField.Len(); i++ {
+			names = append(names, entityField.Index(i).String())
+		}
+	case entityField.Kind() == reflect.Slice && entityField.Type().Elem() == fileType:
+		for i := 0; i < entityField.Len(); i++ {
+			names = append(names, entityField.Index(i).Interface().(File).Filename())
+		}
+	default:
+		return nil, false
+	}
+
+	nonEmptyNames := names[:0]
+	for _, name := range names {
+		if name != "" {
+			nonEmptyNames = append(nonEmptyNames, name)
+		}
+	}
+
+	return nonEmptyNames, true
+}
+
+// setFileNames sets entityField, which can be a string, a slice of strings,
+// a File or a slice of Files, to the given filenames. It returns false if entityField is none of them.
+func setFileNames(entityField reflect.Value, names []string) bool {
+	switch {
+	case entityField.Kind() == reflect.String:
+		if len(names) > 0 {
+			entityField.SetString(names[0])
+		} else {
+			entityField.SetString("")
+		}
+	case entityField.Type() == fileType:
+		file := File{}
+		if len(names) > 0 {
+			file.Name = names[0]
+		}
+		entityField.Set(reflect.ValueOf(file))
+	case entityField.Kind() == reflect.Slice && entityField.Type().Elem().Kind() == reflect.String:
+		values := reflect.MakeSlice(entityField.Type(), len(names), len(names))
+		for i, name := range names {
+			values.Index(i).SetString(name)
+		}
+		entityField.Set(values)
+	case entityField.Kind() == reflect.Slice && entityField.Type().Elem() == fileType:
+		files := make([]File, len(names))
+		for i, name := range names {
+			files[i] = File{Name: name}
+		}
+		entityField.Set(reflect.ValueOf(files))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// pendingUploads returns the files waiting to be uploaded in the file fields of the entity structure s,
+// by column name.
+func pendingUploads(s reflect.Value, collSchema schema.Schema) map[string][]*filesystem.File {
+	uploads := map[string][]*filesystem.File{}
+
+	for _, fp := range planOf(s.Type()).fields {
+		fieldType := fieldFromColumnName(collSchema, fp.columnName)
+		if fieldType == nil || fieldType.Type != schema.FieldTypeFile {
+			continue
+		}
+
+		entityField, ok := fp.value(s)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case entityField.Type() == fileType:
+			if upload := entityField.Interface().(File).Upload; upload != nil {
+				uploads[fp.columnName] = append(uploads[fp.columnName], upload)
+			}
+		case entityField.Kind() == reflect.Slice && entityField.Type().Elem() == fileType:
+			for _, file := range entityField.Interface().([]File) {
+				if file.Upload != nil {
+					uploads[fp.columnName] = append(uploads[fp.columnName], file.Upload)
+				}
+			}
+		}
+	}
+
+	return uploads
+}
+
+// checkFiles returns an error if the file columns of record or the files uploaded into them do not
+// respect the options of their field, as PocketBase record forms check them: the number of files,
+// the size of the uploads and their mime type.
+func checkFiles(record *models.Record, uploads map[string][]*filesystem.File) error {
+	for _, fieldType := range record.Collection().Schema.Fields() {
+		if fieldType.Type != schema.FieldTypeFile {
+			continue
+		}
+
+		options, _ := fieldType.Options.(*schema.FileOptions)
+		if options == nil {
+			continue
+		}
+
+		if count := len(record.GetStringSlice(fieldType.Name)); count > options.MaxSelect {
+			return fmt.Errorf("could not save %d files in %q: no more than %d allowed", count, fieldType.Name, options.MaxSelect)
+		}
+
+		for _, upload := range uploads[fieldType.Name] {
+			if options.MaxSize > 0 {
+				if err := validators.UploadedFileSize(options.MaxSize)(upload); err != nil {
+					return fmt.Errorf("could not upload file into %q: %w", fieldType.Name, err)
+				}
+			}
+
+			if len(options.MimeTypes) > 0 {
+				if err := validators.UploadedFileMimeType(options.MimeTypes)(upload); err != nil {
+					return fmt.Errorf("could not upload file into %q: %w", fieldType.Name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// storedFiles returns the filenames of the file columns of record, by column name.
+func storedFiles(record *models.Record) map[string][]string {
+	files := map[string][]string{}
+	for _, fieldType := range record.Collection().Schema.Fields() {
+		if fieldType.Type == schema.FieldTypeFile {
+			files[fieldType.Name] = record.GetStringSlice(fieldType.Name)
+		}
+	}
+	return files
+}
+
+// removedFiles returns the filenames of previous, the files of record before its update (see storedFiles),
+// which record no longer holds, i.e. the files which were replaced or removed.
+func removedFiles(previous map[string][]string, record *models.Record) []string {
+	removed := []string{}
+	for column, names := range previous {
+		current := record.GetStringSlice(column)
+		for _, name := range names {
+			if !containsString(current, name) {
+				removed = append(removed, name)
+			}
+		}
+	}
+	return removed
+}
+
+// uploadFiles uploads files into the storage directory of record, the same way PocketBase record forms do.
+// On failure, the files uploaded so far are deleted.
+func uploadFiles(newFilesystem func() (*filesystem.System, error), record *models.Record, files map[string][]*filesystem.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	fs, err := newFilesystem()
+	if err != nil {
+		return fmt.Errorf("could not open filesystem: %w", err)
+	}
+	defer fs.Close()
+
+	uploaded := []string{}
+	for _, uploads := range files {
+		for _, file := range uploads {
+			path := record.BaseFilesPath() + "/" + file.Name
+			if err := fs.UploadFile(file, path); err != nil {
+				for _, uploadedPath := range uploaded {
+					fs.Delete(uploadedPath)
+				}
+				return fmt.Errorf("could not upload file %q: %w", file.OriginalName, err)
+			}
+			uploaded = append(uploaded, path)
+		}
+	}
+
+	return nil
+}
+
+// deleteFiles deletes the files names, and their thumbnails, from the storage directory of record,
+// the same way PocketBase record forms do.
+func deleteFiles(newFilesystem func() (*filesystem.System, error), record *models.Record, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	fs, err := newFilesystem()
+	if err != nil {
+		return fmt.Errorf("could not open filesystem: %w", err)
+	}
+	defer fs.Close()
+
+	failed := []string{}
+	for _, name := range names {
+		if err := fs.Delete(record.BaseFilesPath() + "/" + name); err != nil {
+			failed = append(failed, name)
+			continue
+		}
+		fs.DeletePrefix(record.BaseFilesPath() + "/thumbs_" + name + "/")
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not delete files %q", failed)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+func TestDecodeFiles(t *testing.T) {
+	r := models.NewRecord(EntityWithFiles{}.Collection())
+	r.SetId("i7iedw7au80qljq")
+	r.Set("avatar", "avatar.png")
+	r.Set("attachments", `["a.txt","b.txt"]`)
+	r.Set("cover", "cover.png")
+	r.Set("documents", []string{"c.pdf"})
+
+	entity := EntityWithFiles{}
+	if err := Decode(r, &entity); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	expected := EntityWithFiles{
+		Id:          "i7iedw7au80qljq",
+		Avatar:      File{Name: "avatar.png"},
+		Attachments: []File{{Name: "a.txt"}, {Name: "b.txt"}},
+		Cover:       "cover.png",
+		Documents:   []string{"c.pdf"},
+	}
+	if !reflect.DeepEqual(entity, expected) {
+		t.Errorf("expected %v, got %v", expected, entity)
+	}
+}
+
+func TestEncodeFiles(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithFiles{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	upload, err := filesystem.NewFileFromBytes([]byte("foo"), "foo.txt")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	entity := EntityWithFiles{
+		Avatar:      File{Name: "avatar.png"},
+		Attachments: []File{{Name: "a.txt"}, NewFile(upload)},
+		Cover:       "cover.png",
+		Documents:   []string{"c.pdf"},
+	}
+	actual, err := Encode(&entity, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := models.NewRecord(EntityWithFiles{}.Collection())
+	expected.Set("avatar", "avatar.png")
+	expected.Set("attachments", []string{"a.txt", upload.Name})
+	expected.Set("cover", "cover.png")
+	expected.Set("documents", []string{"c.pdf"})
+
+	actualBusinessFields := RecordsColumnValueMap(actual)
+	expectedBusinessFields := RecordsColumnValueMap(expected)
+
+	if !reflect.DeepEqual(actualBusinessFields, expectedBusinessFields) {
+		t.Errorf("expected %v, got %v", expectedBusinessFields, actualBusinessFields)
+	}
+}
+
+func TestRepositorySaveUploadsFiles(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithFiles{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	upload, err := filesystem.NewFileFromBytes([]byte("foo"), "foo.txt")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	entity := EntityWithFiles{Avatar: NewFile(upload)}
+
+	if err := NewRepository[EntityWithFiles](testApp.Dao()).Save(&entity); err == nil {
+		t.Errorf("expected error without filesystem, got nil")
+	}
+
+	repository := NewRepository[EntityWithFiles](testApp.Dao()).WithFilesystem(testApp.NewFilesystem)
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := File{Name: upload.Name}
+	if !reflect.DeepEqual(entity.Avatar, expected) {
+		t.Errorf("expected %v, got %v", expected, entity.Avatar)
+	}
+
+	fs, err := testApp.NewFilesystem()
+	if err != nil {
+		t.Fatalf("could not open filesystem: %v", err)
+	}
+	defer fs.Close()
+
+	coll, err := testApp.Dao().FindCollectionByNameOrId(EntityWithFiles{}.CollectionName())
+	if err != nil {
+		t.Fatalf("could not find collection: %v", err)
+	}
+
+	exists, err := fs.Exists(coll.BaseFilesPath() + "/" + entity.Id + "/" + upload.Name)
+	if err != nil || !exists {
+		t.Errorf("expected uploaded file to exist, got %v (%v)", exists, err)
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	entity := EntityWithFiles{Id: "i7iedw7au80qljq"}
+
+	dataset := []struct {
+		label    string
+		actual   string
+		expected string
+	}{
+		{
+			label:    "file url",
+			actual:   FileURL("http://localhost:8090/", &entity, "a b.png"),
+			expected: "http://localhost:8090/api/files/files/i7iedw7au80qljq/a%20b.png",
+		},
+		{
+			label:    "thumb url",
+			actual:   ThumbURL("http://localhost:8090", &entity, "a.png", "100x100"),
+			expected: "http://localhost:8090/api/files/files/i7iedw7au80qljq/a.png?thumb=100x100",
+		},
+		{
+			label:    "without filename",
+			actual:   FileURL("http://localhost:8090", &entity, ""),
+			expected: "",
+		},
+	}
+
+	for _, tt := range dataset {
+		t.Run(tt.label, func(t *testing.T) {
+			if tt.actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, tt.actual)
+			}
+		})
+	}
+}
+
+func TestRepositorySaveChecksUploads(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithFiles{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	coll, err := testApp.Dao().FindCollectionByNameOrId(EntityWithFiles{}.CollectionName())
+	if err != nil {
+		t.Fatalf("could not find collection: %v", err)
+	}
+	coll.Schema.GetFieldByName("attachments").Options.(*schema.FileOptions).MimeTypes = []string{"image/png"}
+	if err := testApp.Dao().SaveCollection(coll); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	newUpload := func(content []byte, name string) File {
+		upload, err := filesystem.NewFileFromBytes(content, name)
+		if err != nil {
+			t.Fatalf("could not create file: %v", err)
+		}
+		return NewFile(upload)
+	}
+
+	tooManyFiles := make([]string, 11)
+	for i := range tooManyFiles {
+		tooManyFiles[i] = fmt.Sprintf("doc%d.pdf", i)
+	}
+
+	dataset := []struct {
+		label  string
+		entity EntityWithFiles
+	}{
+		{"oversized file", EntityWithFiles{Avatar: newUpload(make([]byte, 2048), "big.txt")}},
+		{"wrong mime type", EntityWithFiles{Attachments: []File{newUpload([]byte("foo"), "foo.txt")}}},
+		{"too many files", EntityWithFiles{Documents: tooManyFiles}},
+	}
+
+	repository := NewRepository[EntityWithFiles](testApp.Dao()).WithFilesystem(testApp.NewFilesystem)
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			entity := data.entity
+			if err := repository.Save(&entity); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+
+			if entity.Id != "" {
+				if exists, _ := repository.Exists(entity.Id); exists {
+					t.Errorf("expected entity not to be saved")
+				}
+			}
+		})
+	}
+}
+
+func TestRepositorySaveDeletesReplacedFiles(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithFiles{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	first, err := filesystem.NewFileFromBytes([]byte("foo"), "foo.txt")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+	second, err := filesystem.NewFileFromBytes([]byte("bar"), "bar.txt")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	repository := NewRepository[EntityWithFiles](testApp.Dao()).WithFilesystem(testApp.NewFilesystem)
+
+	entity := EntityWithFiles{Avatar: NewFile(first), Attachments: []File{NewFile(second)}}
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	replacement, err := filesystem.NewFileFromBytes([]byte("baz"), "baz.txt")
+	if err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	entity.Avatar = NewFile(replacement)
+	entity.Attachments = nil
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	fs, err := testApp.NewFilesystem()
+	if err != nil {
+		t.Fatalf("could not open filesystem: %v", err)
+	}
+	defer fs.Close()
+
+	coll, err := testApp.Dao().FindCollectionByNameOrId(EntityWithFiles{}.CollectionName())
+	if err != nil {
+		t.Fatalf("could not find collection: %v", err)
+	}
+
+	dataset := []struct {
+		label    string
+		name     string
+		expected bool
+	}{
+		{"replaced file", first.Name, false},
+		{"removed file", second.Name, false},
+		{"replacement", replacement.Name, true},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			exists, err := fs.Exists(coll.BaseFilesPath() + "/" + entity.Id + "/" + data.name)
+			if err != nil || exists != data.expected {
+				t.Errorf("expected file to exist %v, got %v (%v)", data.expected, exists, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/resolvers"
+	"github.com/pocketbase/pocketbase/tools/search"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// FindByFilter returns the entities of T matching the given PocketBase filter expression,
+// sorted according to sort (e.g. "-created,title").
+//
+// The filter may contain {:name} placeholders that are safely replaced by the given params.
+// A limit lower or equal to 0 means no limit.
+//
+// Example:
+//
+//	posts, err := orm.FindByFilter[Post](
+//		dao,
+//		"title ~ {:title} && created > {:date}",
+//		"-created",
+//		10,
+//		0,
+//		dbx.Params{"title": "foo", "date": "2023-01-01"},
+//	)
+func FindByFilter[T Entity](dao *daos.Dao, filter string, sort string, limit int, offset int, params ...dbx.Params) ([]*T, error) {
+	return findByFilter[T](dao, nil, nil, filter, sort, limit, offset, params...)
+}
+
+// findByFilter is FindByFilter eager loading the relations described by expands.
+func findByFilter[T Entity](dao *daos.Dao, expands []string, opts []Option, filter string, sort string, limit int, offset int, params ...dbx.Params) ([]*T, error) {
+	if dao == nil {
+		return nil, fmt.Errorf("could not find entities: dao is nil")
+	}
+
+	var zeroValue T
+	coll, err := findCollection(dao, zeroValue.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("could not get entity collection: %w", err)
+	}
+
+	resolver := resolvers.NewRecordFieldResolver(dao, coll, nil, true)
+	query := dao.RecordQuery(coll)
+
+	if filter != "" {
+		replaced, err := replaceFilterParams(filter, params...)
+		if err != nil {
+			return nil, fmt.Errorf("could not build filter %q: %w", filter, err)
+		}
+
+		expr, err := search.FilterData(replaced).BuildExpr(resolver)
+		if err != nil {
+			return nil, fmt.Errorf("could not build filter %q: %w", filter, err)
+		}
+		query.AndWhere(expr)
+	}
+
+	if sort != "" {
+		for _, sortField := range search.ParseSortFromString(sort) {
+			expr, err := sortField.BuildExpr(resolver)
+			if err != nil {
+				return nil, fmt.Errorf("could not build sort %q: %w", sort, err)
+			}
+			query.AndOrderBy(expr)
+		}
+	}
+
+	if err := resolver.UpdateQuery(query); err != nil {
+		return nil, fmt.Errorf("could not resolve filter %q: %w", filter, err)
+	}
+
+	if limit > 0 {
+		query.Limit(int64(limit))
+	}
+
+	if offset > 0 {
+		query.Offset(int64(offset))
+	}
+
+	records := []*models.Record{}
+	if err := query.All(&records); err != nil {
+		return nil, fmt.Errorf("could not execute filter %q: %w", filter, err)
+	}
+
+	return decodeRecords[T](dao, records, expands, opts...)
+}
+
+// filterPlaceholder matches the {:name} placeholders of a filter.
+var filterPlaceholder = regexp.MustCompile(`\{:(\w+)\}`)
+
+// replaceFilterParams replaces every {:name} placeholder of filter with the literal of its value, in a
+// single pass so that the placeholders held by the values are never replaced. The placeholders without
+// value are left as is. Text values are quoted and escaped so they can't alter the filter expression.
+func replaceFilterParams(filter string, params ...dbx.Params) (string, error) {
+	values := dbx.Params{}
+	for _, p := range params {
+		for name, value := range p {
+			values[name] = value
+		}
+	}
+
+	var err error
+	replaced := filterPlaceholder.ReplaceAllStringFunc(filter, func(placeholder string) string {
+		name := filterPlaceholder.FindStringSubmatch(placeholder)[1]
+		value, ok := values[name]
+		if !ok {
+			return placeholder
+		}
+
+		literal, literalErr := filterLiteral(value)
+		if literalErr != nil && err == nil {
+			err = fmt.Errorf("could not replace placeholder %s: %w", placeholder, literalErr)
+		}
+		return literal
+	})
+
+	return replaced, err
+}
+
+// filterLiteral returns value formatted as a PocketBase filter literal.
+// A text ending with a backslash can't be quoted, as it would escape the closing quote.
+func filterLiteral(value any) (string, error) {
+	var text string
+
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), nil
+	case string:
+		text = v
+	case time.Time:
+		text = v.UTC().Format(types.DefaultDateLayout)
+	case fmt.Stringer:
+		text = v.String()
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			text = fmt.Sprint(v)
+		} else {
+			text = string(data)
+		}
+	}
+
+	if strings.HasSuffix(text, `\`) {
+		return "", fmt.Errorf("text %q ending with a backslash can't be quoted", text)
+	}
+
+	return "'" + strings.ReplaceAll(text, "'", `\'`) + "'", nil
+}
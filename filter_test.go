@@ -0,0 +1,189 @@
+package orm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+func TestFindByFilter(t *testing.T) {
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dataset := []struct {
+		label    string
+		filter   string
+		sort     string
+		limit    int
+		offset   int
+		params   []dbx.Params
+		expected []string
+	}{
+		{
+			label:    "without filter",
+			sort:     "text",
+			expected: []string{"bar", "baz", "foo", "qux"},
+		},
+		{
+			label:    "with like and descending sort",
+			filter:   "text ~ 'ba'",
+			sort:     "-text",
+			expected: []string{"baz", "bar"},
+		},
+		{
+			label:    "with logical operators",
+			filter:   "number_int >= 2 || text = 'bar'",
+			sort:     "number_int",
+			expected: []string{"bar", "baz", "qux"},
+		},
+		{
+			label:    "with params",
+			filter:   "text = {:text} || number_int = {:number}",
+			sort:     "text",
+			params:   []dbx.Params{{"text": "foo", "number": 3}},
+			expected: []string{"foo", "qux"},
+		},
+		{
+			label:    "with placeholder in params",
+			filter:   "text = {:a} || text = {:b}",
+			sort:     "text",
+			params:   []dbx.Params{{"a": "{:b}", "b": "bar"}},
+			expected: []string{"bar"},
+		},
+		{
+			label:    "with backslash in params",
+			filter:   "text = {:path} || text = {:text}",
+			sort:     "text",
+			params:   []dbx.Params{{"path": `C:\dir\'`, "text": "foo"}},
+			expected: []string{"foo"},
+		},
+		{
+			label:    "with limit and offset",
+			sort:     "text",
+			limit:    2,
+			offset:   1,
+			expected: []string{"baz", "foo"},
+		},
+	}
+
+	for _, tt := range dataset {
+		t.Run(tt.label, func(t *testing.T) {
+			entities, err := FindByFilter[EntityWithAllPBTypes](testApp.Dao(), tt.filter, tt.sort, tt.limit, tt.offset, tt.params...)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			actual := make([]string, len(entities))
+			for i, entity := range entities {
+				actual[i] = entity.Text
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFindByFilterWithInvalidFilter(t *testing.T) {
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if _, err := FindByFilter[EntityWithAllPBTypes](testApp.Dao(), "unknown = 1", "", 0, 0); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if _, err := FindByFilter[EntityWithAllPBTypes](testApp.Dao(), "", "-unknown", 0, 0); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestReplaceFilterParams(t *testing.T) {
+	date := time.Date(2023, 5, 12, 19, 51, 5, 0, time.UTC)
+
+	dataset := []struct {
+		label    string
+		filter   string
+		params   dbx.Params
+		expected string
+	}{
+		{
+			label:    "with string",
+			filter:   "title = {:title}",
+			params:   dbx.Params{"title": "foo"},
+			expected: "title = 'foo'",
+		},
+		{
+			label:    "with quoted string",
+			filter:   "title = {:title}",
+			params:   dbx.Params{"title": "it's' || 1=1"},
+			expected: `title = 'it\'s\' || 1=1'`,
+		},
+		{
+			label:    "with number, bool and nil",
+			filter:   "a = {:a} && b = {:b} && c = {:c}",
+			params:   dbx.Params{"a": 12.5, "b": true, "c": nil},
+			expected: "a = 12.5 && b = true && c = null",
+		},
+		{
+			label:    "with time",
+			filter:   "created > {:date}",
+			params:   dbx.Params{"date": date},
+			expected: "created > '2023-05-12 19:51:05.000Z'",
+		},
+		{
+			label:    "with unknown placeholder",
+			filter:   "title = {:unknown}",
+			params:   dbx.Params{"title": "foo"},
+			expected: "title = {:unknown}",
+		},
+		{
+			label:    "with placeholder in value",
+			filter:   "a = {:a} && b = {:b}",
+			params:   dbx.Params{"a": "{:b}", "b": "foo"},
+			expected: "a = '{:b}' && b = 'foo'",
+		},
+		{
+			label:    "with backslashes",
+			filter:   "path = {:path}",
+			params:   dbx.Params{"path": `C:\dir\'a'`},
+			expected: `path = 'C:\dir\\'a\''`,
+		},
+	}
+
+	for _, tt := range dataset {
+		t.Run(tt.label, func(t *testing.T) {
+			actual, err := replaceFilterParams(tt.filter, tt.params)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReplaceFilterParamsRejectsUnquotableText(t *testing.T) {
+	if _, err := replaceFilterParams("path = {:path}", dbx.Params{"path": `C:\`}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if _, err := FindByFilter[EntityWithAllPBTypes](testApp.Dao(), "text = {:path}", "", 0, 0, dbx.Params{"path": `C:\`}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
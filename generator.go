@@ -0,0 +1,353 @@
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ormImportPath   = "github.com/tbonnardel/pb-orm"
+	typesImportPath = "github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Generate returns the Go source files of the entities mapping the base, auth and view collections of dao,
+// indexed by file name. Every file holds the entity of one collection, with a typed enum per select field.
+// Only the id, created, updated and schema fields are mapped, the other auth system fields are not.
+//
+// The output only depends on the collections, so that it diffs cleanly once regenerated.
+func Generate(dao *daos.Dao, packageName string) (map[string][]byte, error) {
+	if dao == nil {
+		return nil, fmt.Errorf("could not generate entities: dao is nil")
+	}
+
+	if !token.IsIdentifier(packageName) {
+		return nil, fmt.Errorf("could not generate entities: invalid package name %q", packageName)
+	}
+
+	colls := []*models.Collection{}
+	for _, collType := range []string{models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeView} {
+		found, err := dao.FindCollectionsByType(collType)
+		if err != nil {
+			return nil, fmt.Errorf("could not find %s collections: %w", collType, err)
+		}
+		colls = append(colls, found...)
+	}
+
+	sort.Slice(colls, func(i, j int) bool {
+		return colls[i].Name < colls[j].Name
+	})
+
+	g := &generator{
+		packageName: packageName,
+		typeNames:   map[string]string{},
+		usedNames:   map[string]bool{},
+	}
+
+	for _, coll := range colls {
+		g.typeNames[coll.Id] = g.uniqueName(singular(goName(coll.Name)))
+	}
+
+	files := map[string][]byte{}
+	for _, coll := range colls {
+		src, err := g.generateEntity(coll)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate entity of collection %q: %w", coll.Name, err)
+		}
+		files[generatedFileName(coll.Name)] = src
+	}
+
+	return files, nil
+}
+
+// GenerateFiles writes the files returned by Generate into dir, creating it if needed.
+func GenerateFiles(dao *daos.Dao, dir string, packageName string) error {
+	files, err := Generate(dao, packageName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create directory %q: %w", dir, err)
+	}
+
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+			return fmt.Errorf("could not write file %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// NewGenerateCommand returns the console command generating the entities of the app collections,
+// e.g. app.RootCmd.AddCommand(orm.NewGenerateCommand(app)).
+func NewGenerateCommand(app core.App) *cobra.Command {
+	var dir, packageName string
+
+	command := &cobra.Command{
+		Use:     "generate",
+		Example: "generate --dir=./models",
+		Short:   "Generates the Go entities of the collections",
+		// prevents printing the error log twice
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(command *cobra.Command, args []string) error {
+			if packageName == "" {
+				packageName = filepath.Base(dir)
+			}
+
+			if err := GenerateFiles(app.Dao(), dir, packageName); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(command.OutOrStdout(), "Successfully generated the entities into %q.\n", dir)
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&dir, "dir", "models", "the directory of the generated files")
+	command.Flags().StringVar(&packageName, "package", "", "the package of the generated files (default to the base of dir)")
+
+	return command
+}
+
+// generator holds the Go names given to the generated types, shared by all the generated files.
+type generator struct {
+	packageName string
+	typeNames   map[string]string // by collection id
+	usedNames   map[string]bool
+}
+
+// generatedField is a structure field of a generated entity.
+type generatedField struct {
+	name       string
+	goType     string
+	columnName string
+}
+
+// generatedEnum is the string based type generated for a select field.
+type generatedEnum struct {
+	name      string
+	fieldName string
+	values    []string
+}
+
+// generateEntity returns the formatted Go source of the entity of coll.
+func (g *generator) generateEntity(coll *models.Collection) ([]byte, error) {
+	typeName := g.typeNames[coll.Id]
+	imports := map[string]bool{}
+	fieldNames := map[string]bool{"CollectionName": true}
+
+	fields := []generatedField{{name: "Id", goType: "string", columnName: "id"}}
+	fieldNames["Id"] = true
+
+	enums := []generatedEnum{}
+	for _, field := range coll.Schema.Fields() {
+		name := uniqueIn(fieldNames, goName(field.Name))
+
+		goType := ""
+		switch field.Type {
+		case schema.FieldTypeText, schema.FieldTypeEmail, schema.FieldTypeUrl, schema.FieldTypeEditor:
+			goType = "string"
+		case schema.FieldTypeNumber:
+			goType = "float64"
+		case schema.FieldTypeBool:
+			goType = "bool"
+		case schema.FieldTypeDate:
+			goType = "*time.Time"
+			imports["time"] = true
+		case schema.FieldTypeJson:
+			goType = "types.JsonRaw"
+			imports[typesImportPath] = true
+		case schema.FieldTypeSelect:
+			options, _ := field.Options.(*schema.SelectOptions)
+			enum := generatedEnum{name: g.uniqueName(typeName + name), fieldName: field.Name}
+			if options != nil {
+				enum.values = options.Values
+			}
+			enums = append(enums, enum)
+
+			goType = enum.name
+			if options != nil && options.IsMultiple() {
+				goType = "[]" + enum.name
+			}
+		case schema.FieldTypeRelation:
+			options, _ := field.Options.(*schema.RelationOptions)
+			goType = "string"
+			if options != nil {
+				if relatedName, ok := g.typeNames[options.CollectionId]; ok {
+					goType = "*" + relatedName
+				}
+			}
+			if options != nil && options.IsMultiple() {
+				goType = "[]" + goType
+			}
+		case schema.FieldTypeFile:
+			options, _ := field.Options.(*schema.FileOptions)
+			goType = "orm.File"
+			if options != nil && options.IsMultiple() {
+				goType = "[]orm.File"
+			}
+			imports[ormImportPath] = true
+		default:
+			continue
+		}
+
+		fields = append(fields, generatedField{name: name, goType: goType, columnName: field.Name})
+	}
+
+	if !coll.IsView() {
+		for _, columnName := range []string{schema.FieldNameCreated, schema.FieldNameUpdated} {
+			name := uniqueIn(fieldNames, goName(columnName))
+			fields = append(fields, generatedField{name: name, goType: "time.Time", columnName: columnName})
+		}
+		imports["time"] = true
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by pb-orm from the %q collection. DO NOT EDIT.\n\n", coll.Name)
+	fmt.Fprintf(buf, "package %s\n\n", g.packageName)
+
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for path := range imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		// standard library imports first, the others after a blank line
+		sort.SliceStable(paths, func(i, j int) bool {
+			return !strings.Contains(paths[i], ".") && strings.Contains(paths[j], ".")
+		})
+
+		buf.WriteString("import (\n")
+		for i, path := range paths {
+			if i > 0 && strings.Contains(path, ".") && !strings.Contains(paths[i-1], ".") {
+				buf.WriteString("\n")
+			}
+			if path == ormImportPath {
+				fmt.Fprintf(buf, "orm %q\n", path)
+				continue
+			}
+			fmt.Fprintf(buf, "%q\n", path)
+		}
+		buf.WriteString(")\n\n")
+	}
+
+	fmt.Fprintf(buf, "// %s maps the records of the %q collection.\n", typeName, coll.Name)
+	fmt.Fprintf(buf, "type %s struct {\n", typeName)
+	for _, field := range fields {
+		fmt.Fprintf(buf, "%s %s `orm:%s`\n", field.name, field.goType, strconv.Quote(field.columnName))
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "// CollectionName implements the orm.Entity interface.\n")
+	fmt.Fprintf(buf, "func (%s) CollectionName() string {\nreturn %q\n}\n", typeName, coll.Name)
+
+	for _, enum := range enums {
+		fmt.Fprintf(buf, "\n// %s is a value of the %q select field of the %q collection.\n", enum.name, enum.fieldName, coll.Name)
+		fmt.Fprintf(buf, "type %s string\n", enum.name)
+
+		if len(enum.values) == 0 {
+			continue
+		}
+
+		valueNames := map[string]bool{}
+		buf.WriteString("\nconst (\n")
+		for _, value := range enum.values {
+			valueName := g.uniqueName(enum.name + uniqueIn(valueNames, goName(value)))
+			fmt.Fprintf(buf, "%s %s = %q\n", valueName, enum.name, value)
+		}
+		buf.WriteString(")\n")
+
+		fmt.Fprintf(buf, "\n// EnumValues implements the orm.Enum interface.\n")
+		fmt.Fprintf(buf, "func (%s) EnumValues() []string {\nreturn %#v\n}\n", enum.name, enum.values)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not format source: %w", err)
+	}
+
+	return src, nil
+}
+
+// uniqueName returns name, suffixed by a number if it is already used by another generated type or constant.
+func (g *generator) uniqueName(name string) string {
+	return uniqueIn(g.usedNames, name)
+}
+
+// uniqueIn returns name, suffixed by a number if it is already in used, and adds it to used.
+func uniqueIn(used map[string]bool, name string) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// goName converts s (e.g. a collection or a field name) into an exported Go identifier,
+// e.g. "single_relation" into "SingleRelation".
+func goName(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	name := ""
+	for _, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		name += string(runes)
+	}
+
+	if name == "" {
+		return "X"
+	}
+
+	if first := []rune(name)[0]; !unicode.IsLetter(first) {
+		name = "X" + name
+	}
+
+	return name
+}
+
+// singular returns the singular form of the plural English noun name ends with, e.g. "Categories" into "Category".
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"),
+		strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "ss"), strings.HasSuffix(name, "us"), strings.HasSuffix(name, "is"):
+		return name
+	case strings.HasSuffix(name, "s") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// generatedFileName returns the name of the file generated for the collection collectionName.
+// Leading underscores are trimmed, the go tool ignoring such files.
+func generatedFileName(collectionName string) string {
+	name := strings.TrimLeft(strings.ToLower(collectionName), "_.")
+	if name == "" {
+		name = "collection"
+	}
+	return name + ".gen.go"
+}
@@ -0,0 +1,209 @@
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func setupGeneratorTests() (*tests.TestApp, error) {
+	posts := &models.Collection{Name: "posts", Schema: schema.NewSchema(
+		&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+		&schema.SchemaField{Name: "views", Type: schema.FieldTypeNumber},
+		&schema.SchemaField{Name: "published_at", Type: schema.FieldTypeDate},
+		&schema.SchemaField{Name: "meta", Type: schema.FieldTypeJson},
+		&schema.SchemaField{Name: "status", Type: schema.FieldTypeSelect, Options: &schema.SelectOptions{MaxSelect: 1, Values: []string{"draft", "in review", "published"}}},
+		&schema.SchemaField{Name: "labels", Type: schema.FieldTypeSelect, Options: &schema.SelectOptions{MaxSelect: 2, Values: []string{"go", "GO"}}},
+		&schema.SchemaField{Name: "author", Type: schema.FieldTypeRelation, Options: &schema.RelationOptions{CollectionId: "authors00000001", MinSelect: pointer(0), MaxSelect: pointer(1)}},
+		&schema.SchemaField{Name: "tags", Type: schema.FieldTypeRelation, Options: &schema.RelationOptions{CollectionId: "tags00000000001", MinSelect: pointer(0), MaxSelect: pointer(10)}},
+		&schema.SchemaField{Name: "cover", Type: schema.FieldTypeFile, Options: &schema.FileOptions{MaxSelect: 1, MaxSize: 1024}},
+	)}
+
+	return setupTestApp(Author{}.Collection(), Tag{}.Collection(), posts)
+}
+
+const expectedGeneratedPost = "// Code generated by pb-orm from the \"posts\" collection. DO NOT EDIT.\n" + `
+package models
+
+import (
+	"time"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+	orm "github.com/tbonnardel/pb-orm"
+)
+
+// Post maps the records of the "posts" collection.
+type Post struct {
+	Id          string        ` + "`orm:\"id\"`" + `
+	Title       string        ` + "`orm:\"title\"`" + `
+	Views       float64       ` + "`orm:\"views\"`" + `
+	PublishedAt *time.Time    ` + "`orm:\"published_at\"`" + `
+	Meta        types.JsonRaw ` + "`orm:\"meta\"`" + `
+	Status      PostStatus    ` + "`orm:\"status\"`" + `
+	Labels      []PostLabels  ` + "`orm:\"labels\"`" + `
+	Author      *Author       ` + "`orm:\"author\"`" + `
+	Tags        []*Tag        ` + "`orm:\"tags\"`" + `
+	Cover       orm.File      ` + "`orm:\"cover\"`" + `
+	Created     time.Time     ` + "`orm:\"created\"`" + `
+	Updated     time.Time     ` + "`orm:\"updated\"`" + `
+}
+
+// CollectionName implements the orm.Entity interface.
+func (Post) CollectionName() string {
+	return "posts"
+}
+
+// PostStatus is a value of the "status" select field of the "posts" collection.
+type PostStatus string
+
+const (
+	PostStatusDraft     PostStatus = "draft"
+	PostStatusInReview  PostStatus = "in review"
+	PostStatusPublished PostStatus = "published"
+)
+
+// EnumValues implements the orm.Enum interface.
+func (PostStatus) EnumValues() []string {
+	return []string{"draft", "in review", "published"}
+}
+
+// PostLabels is a value of the "labels" select field of the "posts" collection.
+type PostLabels string
+
+const (
+	PostLabelsGo PostLabels = "go"
+	PostLabelsGO PostLabels = "GO"
+)
+
+// EnumValues implements the orm.Enum interface.
+func (PostLabels) EnumValues() []string {
+	return []string{"go", "GO"}
+}
+`
+
+func TestGenerate(t *testing.T) {
+	testApp, err := setupGeneratorTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	files, err := Generate(testApp.Dao(), "models")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, name := range []string{"authors.gen.go", "tags.gen.go", "posts.gen.go"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected file %q to be generated", name)
+		}
+	}
+
+	if actual := string(files["posts.gen.go"]); actual != expectedGeneratedPost {
+		t.Errorf("expected %s, got %s", expectedGeneratedPost, actual)
+	}
+
+	again, err := Generate(testApp.Dao(), "models")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(files, again) {
+		t.Errorf("expected generation to be deterministic")
+	}
+}
+
+func TestGenerateInvalidPackage(t *testing.T) {
+	testApp, err := setupGeneratorTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if _, err := Generate(testApp.Dao(), "my-models"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestGenerateCommand(t *testing.T) {
+	testApp, err := setupGeneratorTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dir := filepath.Join(t.TempDir(), "entities")
+
+	out := &bytes.Buffer{}
+	command := NewGenerateCommand(testApp)
+	command.SetOut(out)
+	command.SetArgs([]string{"--dir", dir})
+	if err := command.Execute(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if expected := fmt.Sprintf("Successfully generated the entities into %q.\n", dir); out.String() != expected {
+		t.Errorf("expected output %q, got %q", expected, out.String())
+	}
+
+	src, err := os.ReadFile(filepath.Join(dir, "authors.gen.go"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	files, _ := Generate(testApp.Dao(), "entities")
+	if expected := string(files["authors.gen.go"]); string(src) != expected {
+		t.Errorf("expected %s, got %s", expected, string(src))
+	}
+}
+
+func TestGoName(t *testing.T) {
+	dataset := []struct {
+		label    string
+		name     string
+		expected string
+	}{
+		{"snake case", "single_relation", "SingleRelation"},
+		{"camel case", "emailVisibility", "EmailVisibility"},
+		{"spaces", "in review", "InReview"},
+		{"leading digit", "1st", "X1st"},
+		{"empty", "", "X"},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if actual := goName(data.name); actual != data.expected {
+				t.Errorf("expected %q, got %q", data.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSingular(t *testing.T) {
+	dataset := []struct {
+		label    string
+		name     string
+		expected string
+	}{
+		{"s", "Books", "Book"},
+		{"ies", "Categories", "Category"},
+		{"es", "Boxes", "Box"},
+		{"ss", "Access", "Access"},
+		{"us", "Status", "Status"},
+		{"singular", "Foo", "Foo"},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if actual := singular(data.name); actual != data.expected {
+				t.Errorf("expected %q, got %q", data.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package orm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// defaultMaxFileSize is the max size in bytes of the file fields built without a maxSize option.
+const defaultMaxFileSize = 5 << 20
+
+// defaultMaxFiles is the max number of files of the multiple file fields built without a maxSelect option.
+const defaultMaxFiles = 99
+
+// BuildCollections returns the base collections mapped by entities, their schema being inferred from
+// the Go type of their fields and from the following orm tag options:
+//
+//   - type=<field type> overrides the inferred type (e.g. type=email, type=select, type=relation)
+//   - required and unique
+//   - min=<n> and max=<n>, for text and number fields
+//   - values=<a|b|c> and maxSelect=<n>, for select fields (the values of an Enum and of []Enum being inferred)
+//   - collection=<name> and maxSelect=<n>, for relation fields (the collection of *E, []*E, Ref[E] and Refs[E]
+//     being inferred)
+//   - maxSelect=<n> and maxSize=<bytes>, for file fields
+//
+// When dao is not nil, the collections already existing keep their id, indexes and field ids, as well as
+// the fields not mapped by their entity, so that the collections can be saved or imported over them.
+func BuildCollections(dao *daos.Dao, entities ...Entity) ([]*models.Collection, error) {
+	colls := make([]*models.Collection, len(entities))
+	byName := map[string]*models.Collection{}
+
+	// creates all the collections first, so that relations can target any of them
+	for i, entity := range entities {
+		if entity == nil {
+			return nil, fmt.Errorf("could not build collection of nil entity")
+		}
+
+		name := entity.CollectionName()
+		coll := &models.Collection{Name: name, Type: models.CollectionTypeBase}
+
+		if dao != nil {
+			if existing, err := dao.FindCollectionByNameOrId(name); err == nil {
+				coll.Id = existing.Id
+				coll.Type = existing.Type
+				coll.System = existing.System
+				coll.Indexes = existing.Indexes
+				coll.ListRule = existing.ListRule
+				coll.ViewRule = existing.ViewRule
+				coll.CreateRule = existing.CreateRule
+				coll.UpdateRule = existing.UpdateRule
+				coll.DeleteRule = existing.DeleteRule
+				coll.Options = existing.Options
+				coll.Schema = existing.Schema
+			}
+		}
+
+		if coll.Id == "" {
+			coll.RefreshId()
+		}
+
+		colls[i] = coll
+		byName[name] = coll
+	}
+
+	for i, entity := range entities {
+		if err := buildSchema(dao, colls[i], reflect.TypeOf(entity), byName); err != nil {
+			return nil, fmt.Errorf("could not build collection %q: %w", colls[i].Name, err)
+		}
+	}
+
+	return colls, nil
+}
+
+// buildSchema sets into the schema of coll the fields mapped by the entity type t, replacing the existing
+// fields of the same name in place and keeping the others.
+func buildSchema(dao *daos.Dao, coll *models.Collection, t reflect.Type, byName map[string]*models.Collection) error {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("entity given is not a structure")
+	}
+
+	existing := coll.Schema
+	coll.Schema = schema.NewSchema(existing.Fields()...)
+
+	for _, fp := range planOf(t).fields {
+		if fp.readOnly || fp.columnName == schema.FieldNameId {
+			continue
+		}
+
+		field, err := buildField(dao, fp, byName)
+		if err != nil {
+			return fmt.Errorf("could not build field %q: %w", fp.columnName, err)
+		}
+
+		if existingField := existing.GetFieldByName(field.Name); existingField != nil {
+			field.Id = existingField.Id
+		}
+
+		coll.Schema.AddField(field)
+
+		if fp.hasOption("unique") {
+			addUniqueIndex(coll, field.Name)
+		}
+	}
+
+	if err := coll.Schema.Validate(); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	return nil
+}
+
+// buildField returns the schema field mapped by the entity field fp.
+func buildField(dao *daos.Dao, fp *fieldPlan, byName map[string]*models.Collection) (*schema.SchemaField, error) {
+	field := &schema.SchemaField{
+		Name:     fp.columnName,
+		Type:     inferFieldType(fp),
+		Required: fp.hasOption("required"),
+	}
+
+	if err := field.InitOptions(); err != nil {
+		return nil, err
+	}
+
+	multiple := fp.typ.Kind() == reflect.Slice
+
+	switch options := field.Options.(type) {
+	case *schema.TextOptions:
+		min, err := intOption(fp, "min")
+		if err != nil {
+			return nil, err
+		}
+		max, err := intOption(fp, "max")
+		if err != nil {
+			return nil, err
+		}
+		options.Min, options.Max = min, max
+
+	case *schema.NumberOptions:
+		min, err := floatOption(fp, "min")
+		if err != nil {
+			return nil, err
+		}
+		max, err := floatOption(fp, "max")
+		if err != nil {
+			return nil, err
+		}
+		options.Min, options.Max = min, max
+
+	case *schema.SelectOptions:
+		if values, ok := fp.optionValue("values"); ok && values != "" {
+			options.Values = strings.Split(values, "|")
+		} else if values, ok := enumValues(fp.typ); ok {
+			options.Values = values
+		}
+
+		options.MaxSelect = 1
+		if multiple {
+			options.MaxSelect = len(options.Values)
+		}
+		maxSelect, err := intOption(fp, "maxSelect")
+		if err != nil {
+			return nil, err
+		}
+		if maxSelect != nil {
+			options.MaxSelect = *maxSelect
+		}
+
+	case *schema.RelationOptions:
+		target, ok := fp.optionValue("collection")
+		if !ok {
+			related := relatedType(fp.typ)
+			if related == nil {
+				return nil, fmt.Errorf("no related collection, expected a collection option")
+			}
+			target = reflect.Zero(related).Interface().(Entity).CollectionName()
+		}
+
+		related, ok := byName[target]
+		if !ok && dao != nil {
+			related, _ = dao.FindCollectionByNameOrId(target)
+		}
+		if related == nil {
+			return nil, fmt.Errorf("unknown related collection %q", target)
+		}
+		options.CollectionId = related.Id
+
+		options.MaxSelect = pointer(1)
+		if multiple {
+			options.MaxSelect = nil
+		}
+		maxSelect, err := intOption(fp, "maxSelect")
+		if err != nil {
+			return nil, err
+		}
+		if maxSelect != nil {
+			options.MaxSelect = maxSelect
+		}
+
+	case *schema.FileOptions:
+		options.MaxSelect = 1
+		if multiple {
+			options.MaxSelect = defaultMaxFiles
+		}
+		maxSelect, err := intOption(fp, "maxSelect")
+		if err != nil {
+			return nil, err
+		}
+		if maxSelect != nil {
+			options.MaxSelect = *maxSelect
+		}
+
+		options.MaxSize = defaultMaxFileSize
+		maxSize, err := intOption(fp, "maxSize")
+		if err != nil {
+			return nil, err
+		}
+		if maxSize != nil {
+			options.MaxSize = *maxSize
+		}
+	}
+
+	return field, nil
+}
+
+// inferFieldType returns the PocketBase type of the column mapped by the entity field fp,
+// either given by its type option or inferred from its Go type.
+func inferFieldType(fp *fieldPlan) string {
+	if fieldType, ok := fp.optionValue("type"); ok {
+		return fieldType
+	}
+
+	t := fp.typ
+	switch {
+	case t == fileType || (t.Kind() == reflect.Slice && t.Elem() == fileType):
+		return schema.FieldTypeFile
+	case relatedType(t) != nil:
+		return schema.FieldTypeRelation
+	}
+
+	if _, ok := enumValues(t); ok {
+		return schema.FieldTypeSelect
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType || t == dateTimeType {
+		return schema.FieldTypeDate
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return schema.FieldTypeText
+	case reflect.Bool:
+		return schema.FieldTypeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return schema.FieldTypeNumber
+	}
+
+	return schema.FieldTypeJson
+}
+
+// intOption returns the integer value of the key option of fp, or nil if there is none.
+func intOption(fp *fieldPlan, key string) (*int, error) {
+	value, ok := fp.optionValue(key)
+	if !ok {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s option %q: %w", key, value, err)
+	}
+	return &i, nil
+}
+
+// floatOption returns the float value of the key option of fp, or nil if there is none.
+func floatOption(fp *fieldPlan, key string) (*float64, error) {
+	value, ok := fp.optionValue(key)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s option %q: %w", key, value, err)
+	}
+	return &f, nil
+}
+
+// addUniqueIndex adds to coll a unique index on the column columnName, unless it already has one with the same name.
+func addUniqueIndex(coll *models.Collection, columnName string) {
+	name := "idx_unique_" + coll.Name + "_" + columnName
+	for _, index := range coll.Indexes {
+		if strings.Contains(index, "`"+name+"`") {
+			return
+		}
+	}
+
+	indexes := append(types.JsonArray[string]{}, coll.Indexes...)
+	coll.Indexes = append(indexes, fmt.Sprintf("CREATE UNIQUE INDEX `%s` ON `%s` (`%s`)", name, coll.Name, columnName))
+}
+
+// Migration returns the source of a PocketBase Go migration importing colls, in the same format as the
+// migrations generated by the migratecmd plugin. Existing collections are updated, the others created.
+// Like the collections snapshots of migratecmd, the down migration doesn't revert the import, which is
+// stated in the generated file.
+func Migration(packageName string, colls ...*models.Collection) ([]byte, error) {
+	if !token.IsIdentifier(packageName) {
+		return nil, fmt.Errorf("could not generate migration: invalid package name %q", packageName)
+	}
+
+	jsonData, err := json.MarshalIndent(colls, "\t\t", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal collections: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `package %s
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		jsonData := `+"`%s`"+`
+
+		collections := []*models.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collections); err != nil {
+			return err
+		}
+
+		return daos.New(db).ImportCollections(collections, false, nil)
+	}, func(db dbx.Builder) error {
+		// the import of the collections above can't be reverted automatically,
+		// add here the down queries if needed
+		return nil
+	})
+}
+`, packageName, strings.ReplaceAll(string(jsonData), "`", "` + \"`\" + `"))
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not format migration: %w", err)
+	}
+
+	return src, nil
+}
+
+// WriteMigration writes into dir the migration returned by Migration, in a file named like the
+// migratecmd plugin does (i.e. "<unix timestamp>_<name>.go"). It returns the path of the written file.
+func WriteMigration(dir string, name string, colls ...*models.Collection) (string, error) {
+	src, err := Migration(filepath.Base(dir), colls...)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create directory %q: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%d_%s.go", time.Now().Unix(), name))
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		return "", fmt.Errorf("could not write migration %q: %w", path, err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,277 @@
+package orm
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func TestBuildCollections(t *testing.T) {
+	colls, err := BuildCollections(nil, Author{}, Tag{}, Book{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actualLen := len(colls); actualLen != 3 {
+		t.Fatalf("expected 3 collections, got %d", actualLen)
+	}
+
+	authors, tags, books := colls[0], colls[1], colls[2]
+	if authors.Name != "authors" || tags.Name != "tags" || books.Name != "books" {
+		t.Errorf("expected authors, tags and books collections, got %s, %s and %s", authors.Name, tags.Name, books.Name)
+	}
+
+	dataset := []struct {
+		label             string
+		field             *schema.SchemaField
+		expectedType      string
+		expectedRelatedId string
+		expectedMultiple  bool
+	}{
+		{"text", books.Schema.GetFieldByName("title"), schema.FieldTypeText, "", false},
+		{"single relation", books.Schema.GetFieldByName("author"), schema.FieldTypeRelation, authors.Id, false},
+		{"multiple relation", books.Schema.GetFieldByName("tags"), schema.FieldTypeRelation, tags.Id, true},
+		{"relation of a collection built before", tags.Schema.GetFieldByName("owner"), schema.FieldTypeRelation, authors.Id, false},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if data.field == nil {
+				t.Fatalf("expected field, got nil")
+			}
+
+			if data.field.Type != data.expectedType {
+				t.Errorf("expected type %q, got %q", data.expectedType, data.field.Type)
+			}
+
+			if options, ok := data.field.Options.(*schema.RelationOptions); ok {
+				if options.CollectionId != data.expectedRelatedId {
+					t.Errorf("expected collection id %q, got %q", data.expectedRelatedId, options.CollectionId)
+				}
+				if options.IsMultiple() != data.expectedMultiple {
+					t.Errorf("expected multiple to be %v", data.expectedMultiple)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildCollectionsWithOptions(t *testing.T) {
+	colls, err := BuildCollections(nil, Author{}, EntityWithSchemaOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	coll := colls[1]
+	if actualLen := len(coll.Schema.Fields()); actualLen != 12 {
+		t.Errorf("expected 12 fields, got %d", actualLen)
+	}
+
+	title := coll.Schema.GetFieldByName("title")
+	if !title.Required || *title.Options.(*schema.TextOptions).Min != 3 || *title.Options.(*schema.TextOptions).Max != 100 {
+		t.Errorf("unexpected title field %v", title)
+	}
+
+	if expected := []string{"CREATE UNIQUE INDEX `idx_unique_options_slug` ON `options` (`slug`)"}; !reflect.DeepEqual([]string(coll.Indexes), expected) {
+		t.Errorf("expected indexes %v, got %v", expected, coll.Indexes)
+	}
+
+	expectedTypes := map[string]string{
+		"email":     schema.FieldTypeEmail,
+		"rating":    schema.FieldTypeNumber,
+		"status":    schema.FieldTypeSelect,
+		"labels":    schema.FieldTypeSelect,
+		"owner":     schema.FieldTypeRelation,
+		"authors":   schema.FieldTypeRelation,
+		"cover":     schema.FieldTypeFile,
+		"documents": schema.FieldTypeFile,
+		"published": schema.FieldTypeDate,
+		"meta":      schema.FieldTypeJson,
+	}
+	for name, expected := range expectedTypes {
+		if actual := coll.Schema.GetFieldByName(name).Type; actual != expected {
+			t.Errorf("expected field %q of type %q, got %q", name, expected, actual)
+		}
+	}
+
+	if max := *coll.Schema.GetFieldByName("rating").Options.(*schema.NumberOptions).Max; max != 5 {
+		t.Errorf("expected max 5, got %v", max)
+	}
+
+	labels := coll.Schema.GetFieldByName("labels").Options.(*schema.SelectOptions)
+	if !reflect.DeepEqual(labels.Values, []string{"a", "b", "c"}) || labels.MaxSelect != 2 {
+		t.Errorf("unexpected labels options %v", labels)
+	}
+
+	if status := coll.Schema.GetFieldByName("status").Options.(*schema.SelectOptions); status.MaxSelect != 1 {
+		t.Errorf("expected single select, got %v", status)
+	}
+
+	if owner := coll.Schema.GetFieldByName("owner").Options.(*schema.RelationOptions); owner.CollectionId != colls[0].Id {
+		t.Errorf("expected relation to authors, got %v", owner)
+	}
+
+	if authors := coll.Schema.GetFieldByName("authors").Options.(*schema.RelationOptions); *authors.MaxSelect != 3 {
+		t.Errorf("expected 3 authors at most, got %v", authors)
+	}
+
+	if cover := coll.Schema.GetFieldByName("cover").Options.(*schema.FileOptions); cover.MaxSize != 1024 || cover.MaxSelect != 1 {
+		t.Errorf("unexpected cover options %v", cover)
+	}
+
+	if documents := coll.Schema.GetFieldByName("documents").Options.(*schema.FileOptions); documents.MaxSelect != defaultMaxFiles {
+		t.Errorf("unexpected documents options %v", documents)
+	}
+}
+
+func TestBuildCollectionsWithInlineStructs(t *testing.T) {
+	colls, err := BuildCollections(nil, EntityWithInlineStructs{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expectedTypes := map[string]string{
+		"addr_street":     schema.FieldTypeText,
+		"addr_geo_lat":    schema.FieldTypeNumber,
+		"billing_geo_lng": schema.FieldTypeNumber,
+	}
+	for name, expected := range expectedTypes {
+		field := colls[0].Schema.GetFieldByName(name)
+		if field == nil || field.Type != expected {
+			t.Errorf("expected field %q of type %q, got %v", name, expected, field)
+		}
+	}
+
+	if actualLen := len(colls[0].Schema.Fields()); actualLen != 9 {
+		t.Errorf("expected 9 fields, got %d", actualLen)
+	}
+}
+
+func TestBuildCollectionsErrors(t *testing.T) {
+	if _, err := BuildCollections(nil, Tag{}); err == nil {
+		t.Errorf("expected unknown related collection error, got nil")
+	}
+
+	if _, err := BuildCollections(nil, nil); err == nil {
+		t.Errorf("expected nil entity error, got nil")
+	}
+}
+
+func TestBuildCollectionsKeepsExisting(t *testing.T) {
+	testApp, err := tests.NewTestApp()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	colls, err := BuildCollections(testApp.Dao(), Author{}, Tag{}, Book{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := testApp.Dao().ImportCollections(colls, false, nil); err != nil {
+		t.Fatalf("could not import collections: %v", err)
+	}
+
+	// a field added from the admin UI, not mapped by the entity
+	colls[2].Schema.AddField(&schema.SchemaField{Name: "notes", Type: schema.FieldTypeText})
+	if err := testApp.Dao().SaveCollection(colls[2]); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	rebuilt, err := BuildCollections(testApp.Dao(), Book{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rebuilt[0].Id != colls[2].Id {
+		t.Errorf("expected collection id %q, got %q", colls[2].Id, rebuilt[0].Id)
+	}
+
+	if expected, actual := colls[2].Schema.GetFieldByName("title").Id, rebuilt[0].Schema.GetFieldByName("title").Id; actual != expected {
+		t.Errorf("expected field id %q, got %q", expected, actual)
+	}
+
+	if notes := rebuilt[0].Schema.GetFieldByName("notes"); notes == nil {
+		t.Errorf("expected unmapped field notes to be kept")
+	}
+
+	if err := testApp.Dao().ImportCollections(rebuilt, false, nil); err != nil {
+		t.Fatalf("could not import rebuilt collections: %v", err)
+	}
+
+	books := NewRepository[Book](testApp.Dao())
+	if err := NewRepository[Author](testApp.Dao()).Save(&Author{Id: "author000000001", Name: "foo"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	book := &Book{Title: "bar", Author: &Author{Id: "author000000001"}}
+	if err := books.Save(book); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := books.With("author").FindById(book.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if actual.Author == nil || actual.Author.Name != "foo" {
+		t.Errorf("expected author foo, got %v", actual.Author)
+	}
+}
+
+func TestMigration(t *testing.T) {
+	colls, err := BuildCollections(nil, Author{}, Tag{}, Book{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	src, err := Migration("migrations", colls...)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "migration.go", src, 0)
+	if err != nil {
+		t.Fatalf("expected valid source, got %v", err)
+	}
+
+	if file.Name.Name != "migrations" {
+		t.Errorf("expected package migrations, got %s", file.Name.Name)
+	}
+
+	for _, expected := range []string{"m.Register(", "ImportCollections(collections, false, nil)", `"name": "books"`, colls[0].Id, "can't be reverted automatically"} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("expected migration to contain %q", expected)
+		}
+	}
+
+	if _, err := Migration("my-migrations", colls...); err == nil {
+		t.Errorf("expected invalid package error, got nil")
+	}
+}
+
+func TestWriteMigration(t *testing.T) {
+	colls, err := BuildCollections(nil, Author{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	path, err := WriteMigration(t.TempDir()+"/migrations", "create_authors", colls...)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasSuffix(path, "_create_authors.go") {
+		t.Errorf("expected migration file name, got %s", path)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected migration file, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package orm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// maxExactInt is the greatest integer n such that float64 holds every integer of [-n, n].
+const maxExactInt = 1 << 53
+
+// numberOf returns the value of v, an integer or a float, as the float64 stored by SQLite. It returns
+// an error if v is an integer n with |n| > 2^53, out of the range where every integer is stored exactly,
+// or a float not finite. It returns false if v is not a number.
+func numberOf(v reflect.Value) (float64, bool, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i < -maxExactInt || i > maxExactInt {
+			return 0, true, fmt.Errorf("%d can't be stored exactly as a float64, |n| > 2^53", i)
+		}
+		return float64(i), true, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if u > maxExactInt {
+			return 0, true, fmt.Errorf("%d can't be stored exactly as a float64, n > 2^53", u)
+		}
+		return float64(u), true, nil
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, true, fmt.Errorf("%v is not a finite number", f)
+		}
+		return f, true, nil
+	}
+
+	return 0, false, nil
+}
+
+// setNumber sets v, an integer or a float, to f. It returns an error if f can't be held by v, i.e. f has
+// decimals and v is an integer, or f overflows v. It returns false if v is not a number.
+func setNumber(v reflect.Value, f float64) (bool, error) {
+	overflow := fmt.Errorf("%v overflows %s", f, v.Type())
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f != math.Trunc(f) {
+			return true, fmt.Errorf("%v has decimals, %s only holds integers", f, v.Type())
+		}
+		if f < math.MinInt64 || f >= math.MaxInt64 || v.OverflowInt(int64(f)) {
+			return true, overflow
+		}
+		v.SetInt(int64(f))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f != math.Trunc(f) {
+			return true, fmt.Errorf("%v has decimals, %s only holds integers", f, v.Type())
+		}
+		if f < 0 || f >= math.MaxUint64 || v.OverflowUint(uint64(f)) {
+			return true, overflow
+		}
+		v.SetUint(uint64(f))
+
+	case reflect.Float32, reflect.Float64:
+		if v.OverflowFloat(f) {
+			return true, overflow
+		}
+		v.SetFloat(f)
+
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// checkNumberRange returns an error if f is out of the min and max of the number column fieldType.
+// Zero is the blank value of number columns, it is always allowed.
+func checkNumberRange(fieldType *schema.SchemaField, f float64) error {
+	options, ok := fieldType.Options.(*schema.NumberOptions)
+	if !ok || f == 0 {
+		return nil
+	}
+
+	if options.Min != nil && f < *options.Min {
+		return fmt.Errorf("%v is less than the min %v of the column", f, *options.Min)
+	}
+	if options.Max != nil && f > *options.Max {
+		return fmt.Errorf("%v is greater than the max %v of the column", f, *options.Max)
+	}
+	return nil
+}
+
+// noDecimal wraps the conversion funcs of a field tagged with the noDecimal option,
+// so that they reject the numbers with decimals.
+func noDecimal(encode encodeFunc, decode decodeFunc) (encodeFunc, decodeFunc) {
+	checkDecimals := func(field *schema.SchemaField, value any) error {
+		if f, ok := value.(float64); ok && field.Type == schema.FieldTypeNumber && f != math.Trunc(f) {
+			return fmt.Errorf("%v has decimals, the field has the noDecimal option", f)
+		}
+		return nil
+	}
+
+	encodeNoDecimal := func(field *schema.SchemaField, value reflect.Value) (any, error) {
+		encoded, err := encode(field, value)
+		if err != nil {
+			return nil, err
+		}
+		return encoded, checkDecimals(field, encoded)
+	}
+
+	decodeNoDecimal := func(record *models.Record, field *schema.SchemaField, rawValue any, value reflect.Value, opts *mappingOptions) error {
+		if err := checkDecimals(field, rawValue); err != nil {
+			return err
+		}
+		return decode(record, field, rawValue, value, opts)
+	}
+
+	return encodeNoDecimal, decodeNoDecimal
+}
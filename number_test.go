@@ -0,0 +1,145 @@
+package orm
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNumbers(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithNumbers{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity := EntityWithNumbers{Small: -128, Count: 42, Big: 1 << 53, Whole: 12, Rating: 4.5}
+	record, err := Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual := EntityWithNumbers{}
+	if err := Decode(record, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, entity) {
+		t.Errorf("expected %v, got %v", entity, actual)
+	}
+}
+
+func TestDecodeNumbersErrors(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithNumbers{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dataset := []struct {
+		label         string
+		column        string
+		value         float64
+		expectedField string
+	}{
+		{"overflow", "small", 128, "Small"},
+		{"underflow", "small", -129, "Small"},
+		{"negative unsigned", "count", -5, "Count"},
+		{"decimals in integer", "count", 3.7, "Count"},
+		{"unsigned overflow", "big", math.Pow(2, 64), "Big"},
+		{"no decimal option", "whole", 1.5, "Whole"},
+		{"less than min", "rating", 0.5, "Rating"},
+		{"greater than max", "rating", 6, "Rating"},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			record, err := Encode(&EntityWithNumbers{}, testApp.Dao(), Strict())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			record.Set(data.column, data.value)
+
+			var mappingErr *MappingError
+			if err := Decode(record, &EntityWithNumbers{}, Strict()); !errors.As(err, &mappingErr) {
+				t.Fatalf("expected *MappingError, got %v", err)
+			}
+
+			if len(mappingErr.Fields) != 1 || mappingErr.Fields[0].Field != data.expectedField {
+				t.Errorf("expected %s field error, got %v", data.expectedField, mappingErr)
+			}
+		})
+	}
+}
+
+func TestEncodeNumbersErrors(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithNumbers{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dataset := []struct {
+		label         string
+		entity        EntityWithNumbers
+		expectedField string
+	}{
+		{"inexact uint64", EntityWithNumbers{Big: 1<<53 + 1}, "Big"},
+		{"exact uint64 above 2^53", EntityWithNumbers{Big: 1 << 54}, "Big"},
+		{"max uint64", EntityWithNumbers{Big: math.MaxUint64}, "Big"},
+		{"no decimal option", EntityWithNumbers{Whole: 0.5}, "Whole"},
+		{"out of range", EntityWithNumbers{Rating: 10}, "Rating"},
+		{"not finite", EntityWithNumbers{Rating: float32(math.Inf(1))}, "Rating"},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			var mappingErr *MappingError
+			if _, err := Encode(&data.entity, testApp.Dao(), Strict()); !errors.As(err, &mappingErr) {
+				t.Fatalf("expected *MappingError, got %v", err)
+			}
+
+			if len(mappingErr.Fields) != 1 || mappingErr.Fields[0].Field != data.expectedField {
+				t.Errorf("expected %s field error, got %v", data.expectedField, mappingErr)
+			}
+		})
+	}
+}
+
+func TestNumberOf(t *testing.T) {
+	dataset := []struct {
+		label         string
+		value         any
+		expected      float64
+		expectedError bool
+	}{
+		{"int", 42, 42, false},
+		{"min exact int64", int64(-1 << 53), -1 << 53, false},
+		{"max exact int64", int64(1 << 53), 1 << 53, false},
+		{"int64 below -2^53", int64(-1<<53 - 1), 0, true},
+		{"int64 above 2^53", int64(1<<53 + 2), 0, true},
+		{"min int64", int64(math.MinInt64), 0, true},
+		{"max exact uint64", uint64(1 << 53), 1 << 53, false},
+		{"uint64 above 2^53", uint64(1<<53 + 2), 0, true},
+		{"float", 4.5, 4.5, false},
+		{"not a number", math.NaN(), 0, true},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			actual, ok, err := numberOf(reflect.ValueOf(data.value))
+			if !ok {
+				t.Fatalf("expected a number")
+			}
+
+			if (err != nil) != data.expectedError {
+				t.Fatalf("expected error %v, got %v", data.expectedError, err)
+			}
+
+			if actual != data.expected {
+				t.Errorf("expected %v, got %v", data.expected, actual)
+			}
+		})
+	}
+}
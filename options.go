@@ -0,0 +1,110 @@
+package orm
+
+import (
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// Option configures how entities are encoded and decoded.
+type Option func(*mappingOptions)
+
+// mappingOptions are the options of a single Encode or Decode call.
+type mappingOptions struct {
+	strict      bool
+	onError     func(err *FieldError)
+	location    *time.Location
+	changedOnly bool
+	baseline    *models.Record
+}
+
+// Strict makes Encode and Decode return a *MappingError listing the fields which could not be mapped,
+// instead of skipping them.
+func Strict() Option {
+	return func(o *mappingOptions) {
+		o.strict = true
+	}
+}
+
+// WithErrorHandler makes Encode and Decode report the fields skipped in lenient mode to fn,
+// instead of the handler set by SetErrorHandler.
+func WithErrorHandler(fn func(err *FieldError)) Option {
+	return func(o *mappingOptions) {
+		o.onError = fn
+	}
+}
+
+// InLocation makes Decode set the time.Time fields in loc, instead of UTC.
+// The types.DateTime fields are always in UTC.
+func InLocation(loc *time.Location) Option {
+	return func(o *mappingOptions) {
+		o.location = loc
+	}
+}
+
+// ChangedOnly makes EncodeInto set only the columns whose values differ from the current values of the record.
+func ChangedOnly() Option {
+	return func(o *mappingOptions) {
+		o.changedOnly = true
+	}
+}
+
+// changedSince makes EncodeInto set only the columns whose values differ from the values of baseline,
+// e.g. the record a tracked entity was decoded from.
+func changedSince(baseline *models.Record) Option {
+	return func(o *mappingOptions) {
+		o.changedOnly = true
+		o.baseline = baseline
+	}
+}
+
+var defaultLogger = log.New(os.Stderr, "orm: ", log.LstdFlags)
+
+// logFieldError is the default error handler, writing err to the standard error.
+func logFieldError(err *FieldError) {
+	defaultLogger.Print(err)
+}
+
+var errorHandler atomic.Pointer[func(err *FieldError)]
+
+func init() {
+	SetErrorHandler(logFieldError)
+}
+
+// SetErrorHandler sets the handler to which the fields skipped in lenient mode are reported by default.
+// The default handler writes them to the standard error; a nil fn discards them.
+func SetErrorHandler(fn func(err *FieldError)) {
+	if fn == nil {
+		fn = func(*FieldError) {}
+	}
+	errorHandler.Store(&fn)
+}
+
+// newMappingOptions returns the mapping options configured by opts.
+func newMappingOptions(opts []Option) *mappingOptions {
+	o := &mappingOptions{onError: *errorHandler.Load()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.onError == nil {
+		o.onError = func(*FieldError) {}
+	}
+	return o
+}
+
+// handle returns err as is in strict mode or if it is not a *MappingError. Otherwise the mapping is lenient:
+// the field errors are reported to the error handler and nil is returned.
+func (o *mappingOptions) handle(err error) error {
+	mappingErr, ok := err.(*MappingError)
+	if !ok || o.strict {
+		return err
+	}
+
+	for _, fieldErr := range mappingErr.Fields {
+		o.onError(fieldErr)
+	}
+	return nil
+}
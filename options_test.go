@@ -0,0 +1,45 @@
+package orm
+
+import "testing"
+
+func TestSetErrorHandler(t *testing.T) {
+	defer SetErrorHandler(logFieldError)
+
+	fieldErrs := []*FieldError{}
+	SetErrorHandler(func(err *FieldError) {
+		fieldErrs = append(fieldErrs, err)
+	})
+
+	entity := EntityWithSchemaMismatches{}
+	if err := Decode(recordExample, &entity); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if actualLen := len(fieldErrs); actualLen != 2 {
+		t.Errorf("expected 2 reported field errors, got %d", actualLen)
+	}
+
+	SetErrorHandler(nil)
+	if err := Decode(recordExample, &entity); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if actualLen := len(fieldErrs); actualLen != 2 {
+		t.Errorf("expected discarded field errors, got %d", actualLen)
+	}
+}
+
+func TestWithErrorHandlerOverridesDefault(t *testing.T) {
+	defer SetErrorHandler(logFieldError)
+
+	SetErrorHandler(func(err *FieldError) {
+		t.Errorf("unexpected call of the default handler with %v", err)
+	})
+
+	reported := 0
+	entity := EntityWithSchemaMismatches{}
+	if err := Decode(recordExample, &entity, WithErrorHandler(func(*FieldError) { reported++ })); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if reported != 2 {
+		t.Errorf("expected 2 reported field errors, got %d", reported)
+	}
+}
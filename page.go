@@ -0,0 +1,232 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/resolvers"
+	"github.com/pocketbase/pocketbase/tools/search"
+)
+
+// expandQueryParam is the PocketBase list query parameter holding the relations to expand.
+const expandQueryParam = "expand"
+
+// Page is a paginated list of entities.
+// Its JSON representation matches PocketBase records list responses, see MarshalJSON.
+type Page[T Entity] struct {
+	Page       int  `json:"page"`
+	PerPage    int  `json:"perPage"`
+	TotalItems int  `json:"totalItems"`
+	TotalPages int  `json:"totalPages"`
+	Items      []*T `json:"items"`
+
+	collection *models.Collection
+	records    []*models.Record
+}
+
+// MarshalJSON implements the [json.Marshaler] interface. The items are represented as PocketBase records:
+// by their column names, along with the collectionId, collectionName and expand data of the records they
+// were found from, and the email of the auth records only when visible.
+func (p Page[T]) MarshalJSON() ([]byte, error) {
+	items := make([]map[string]any, len(p.Items))
+	for i, item := range p.Items {
+		if item == nil {
+			continue
+		}
+
+		var record *models.Record
+		if i < len(p.records) {
+			record = p.records[i]
+		} else if p.collection != nil {
+			record = models.NewRecord(p.collection)
+		} else {
+			return nil, fmt.Errorf("could not marshal item %d: page has no collection", i)
+		}
+
+		exported, err := exportEntity(reflect.ValueOf(item).Elem(), record)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal item %d: %w", i, err)
+		}
+		items[i] = exported
+	}
+
+	return json.Marshal(struct {
+		Page       int              `json:"page"`
+		PerPage    int              `json:"perPage"`
+		TotalItems int              `json:"totalItems"`
+		TotalPages int              `json:"totalPages"`
+		Items      []map[string]any `json:"items"`
+	}{p.Page, p.PerPage, p.TotalItems, p.TotalPages, items})
+}
+
+// exportEntity returns the public export of record (see models.Record.PublicExport) holding the current
+// values of the entity structure s, leaving record untouched.
+func exportEntity(s reflect.Value, record *models.Record) (map[string]any, error) {
+	exported := record.PublicExport()
+
+	columns, mappingErr := encodeColumns(s, record.Collection().Schema, nil)
+	if err := mappingErr.errOrNil(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range columns {
+		exported[c.fieldType.Name] = c.fieldType.PrepareValue(c.value)
+	}
+	return exported, nil
+}
+
+// FindPage returns the given page of the entities of T matching the PocketBase filter expression,
+// sorted according to sort. Filter placeholders are handled as in FindByFilter.
+//
+// Page and perPage are normalized the same way PocketBase does for list requests.
+func FindPage[T Entity](dao *daos.Dao, page int, perPage int, filter string, sort string, params ...dbx.Params) (*Page[T], error) {
+	return findPage[T](dao, nil, nil, page, perPage, filter, sort, params...)
+}
+
+// findPage is FindPage eager loading the relations described by expands.
+func findPage[T Entity](dao *daos.Dao, expands []string, opts []Option, page int, perPage int, filter string, sort string, params ...dbx.Params) (*Page[T], error) {
+	provider, coll, err := newPageProvider[T](dao, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced, err := replaceFilterParams(filter, params...)
+	if err != nil {
+		return nil, fmt.Errorf("could not build filter %q: %w", filter, err)
+	}
+
+	provider.Page(page).
+		PerPage(perPage).
+		AddFilter(search.FilterData(replaced))
+
+	if sort != "" {
+		provider.Sort(search.ParseSortFromString(sort))
+	}
+
+	found, err := execPage[T](dao, coll, provider, expands, fetchRelated(dao), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return found, showEmails(dao, found.records, nil)
+}
+
+// ParsePage returns the page of entities of T described by urlQuery, which follows the PocketBase list
+// query parameters (page, perPage, sort, filter and expand), with the access rules the PocketBase records
+// list API applies to the request described by info. Unless info holds an admin:
+//   - the ListRule of the collection filters the entities, a nil rule denying the access;
+//   - the relations whose collection ViewRule denies the access are not expanded;
+//   - the filter and sort can't use the hidden fields, nor the @collection and @request fields.
+//
+// A nil info is a guest request.
+//
+// Example:
+//
+//	page, err := orm.ParsePage[Post](dao, apis.RequestData(c), c.QueryParams().Encode())
+func ParsePage[T Entity](dao *daos.Dao, info *models.RequestData, urlQuery string) (*Page[T], error) {
+	if info == nil {
+		info = &models.RequestData{}
+	}
+
+	params, err := url.ParseQuery(urlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse query %q: %w", urlQuery, err)
+	}
+
+	if info.Admin == nil {
+		conditions := params.Get(search.FilterQueryParam) + params.Get(search.SortQueryParam)
+		for _, field := range []string{"@collection.", "@request."} {
+			if strings.Contains(conditions, field) {
+				return nil, fmt.Errorf("could not parse query %q: only admins can use %s fields", urlQuery, field)
+			}
+		}
+	}
+
+	provider, coll, err := newPageProvider[T](dao, info)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := provider.Parse(urlQuery); err != nil {
+		return nil, fmt.Errorf("could not parse query %q: %w", urlQuery, err)
+	}
+
+	var expands []string
+	if expand := params.Get(expandQueryParam); expand != "" {
+		expands = strings.Split(expand, ",")
+	}
+
+	page, err := execPage[T](dao, coll, provider, expands, fetchViewable(dao, info))
+	if err != nil {
+		return nil, err
+	}
+	return page, showEmails(dao, page.records, info)
+}
+
+// newPageProvider returns the collection of T and a search.Provider on it. The entities are searched with
+// the access rules applied to the request described by info, or without any access rule if info is nil.
+func newPageProvider[T Entity](dao *daos.Dao, info *models.RequestData) (*search.Provider, *models.Collection, error) {
+	if dao == nil {
+		return nil, nil, fmt.Errorf("could not find entities: dao is nil")
+	}
+
+	var zeroValue T
+	coll, err := findCollection(dao, zeroValue.CollectionName())
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get entity collection: %w", err)
+	}
+
+	if info != nil && info.Admin == nil && coll.ListRule == nil {
+		return nil, nil, fmt.Errorf("could not list entities of collection %q: only admins can list them", coll.Name)
+	}
+
+	// the hidden fields can be searched by admins only
+	resolver := resolvers.NewRecordFieldResolver(dao, coll, info, info == nil || info.Admin != nil)
+	provider := search.NewProvider(resolver).Query(dao.RecordQuery(coll))
+
+	// views have no rowid to count
+	if coll.IsView() {
+		provider.CountCol(schema.FieldNameId)
+	}
+
+	if info != nil && info.Admin == nil && *coll.ListRule != "" {
+		provider.AddFilter(search.FilterData(*coll.ListRule))
+	}
+
+	return provider, coll, nil
+}
+
+// execPage executes provider and decodes the found records into a Page of T,
+// eager loading with fetch the relations described by expands.
+func execPage[T Entity](dao *daos.Dao, coll *models.Collection, provider *search.Provider, expands []string, fetch daos.ExpandFetchFunc, opts ...Option) (*Page[T], error) {
+	records := []*models.Record{}
+	result, err := provider.Exec(&records)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute search: %w", err)
+	}
+
+	if err := expandRecordsWith(dao, records, expands, fetch); err != nil {
+		return nil, err
+	}
+
+	entities, err := decodeRecords[T](dao, records, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page[T]{
+		Page:       result.Page,
+		PerPage:    result.PerPage,
+		TotalItems: result.TotalItems,
+		TotalPages: result.TotalPages,
+		Items:      entities,
+		collection: coll,
+		records:    records,
+	}, nil
+}
@@ -0,0 +1,356 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func TestFindPage(t *testing.T) {
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dataset := []struct {
+		label              string
+		page               int
+		perPage            int
+		filter             string
+		expectedPage       int
+		expectedTotalItems int
+		expectedTotalPages int
+		expectedItems      []string
+	}{
+		{
+			label:              "first page",
+			page:               1,
+			perPage:            3,
+			expectedPage:       1,
+			expectedTotalItems: 4,
+			expectedTotalPages: 2,
+			expectedItems:      []string{"bar", "baz", "foo"},
+		},
+		{
+			label:              "last page",
+			page:               2,
+			perPage:            3,
+			expectedPage:       2,
+			expectedTotalItems: 4,
+			expectedTotalPages: 2,
+			expectedItems:      []string{"qux"},
+		},
+		{
+			label:              "out of range page",
+			page:               5,
+			perPage:            3,
+			expectedPage:       2,
+			expectedTotalItems: 4,
+			expectedTotalPages: 2,
+			expectedItems:      []string{"qux"},
+		},
+		{
+			label:              "with filter",
+			page:               1,
+			perPage:            3,
+			filter:             "text ~ 'ba'",
+			expectedPage:       1,
+			expectedTotalItems: 2,
+			expectedTotalPages: 1,
+			expectedItems:      []string{"bar", "baz"},
+		},
+	}
+
+	for _, tt := range dataset {
+		t.Run(tt.label, func(t *testing.T) {
+			page, err := FindPage[EntityWithAllPBTypes](testApp.Dao(), tt.page, tt.perPage, tt.filter, "text")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if page.Page != tt.expectedPage {
+				t.Errorf("expected page %d, got %d", tt.expectedPage, page.Page)
+			}
+			if page.PerPage != tt.perPage {
+				t.Errorf("expected perPage %d, got %d", tt.perPage, page.PerPage)
+			}
+			if page.TotalItems != tt.expectedTotalItems {
+				t.Errorf("expected totalItems %d, got %d", tt.expectedTotalItems, page.TotalItems)
+			}
+			if page.TotalPages != tt.expectedTotalPages {
+				t.Errorf("expected totalPages %d, got %d", tt.expectedTotalPages, page.TotalPages)
+			}
+
+			actual := make([]string, len(page.Items))
+			for i, entity := range page.Items {
+				actual[i] = entity.Text
+			}
+			if fmt.Sprint(actual) != fmt.Sprint(tt.expectedItems) {
+				t.Errorf("expected %v, got %v", tt.expectedItems, actual)
+			}
+		})
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	page, err := ParsePage[EntityWithAllPBTypes](testApp.Dao(), asAdmin, "page=2&perPage=1&sort=-text&filter=number_int>0")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if page.TotalItems != 3 || page.TotalPages != 3 {
+		t.Errorf("expected 3 items on 3 pages, got %d items on %d pages", page.TotalItems, page.TotalPages)
+	}
+
+	if len(page.Items) != 1 || page.Items[0].Text != "baz" {
+		t.Errorf("expected [baz], got %v", page.Items)
+	}
+}
+
+func TestPageJSON(t *testing.T) {
+	page := Page[EntityWithAllPBTypes]{Page: 1, PerPage: 30, Items: []*EntityWithAllPBTypes{}}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual := map[string]any{}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := map[string]any{
+		"page":       float64(1),
+		"perPage":    float64(30),
+		"totalItems": float64(0),
+		"totalPages": float64(0),
+		"items":      []any{},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestPageJSONItems(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	page, err := NewRepository[Book](testApp.Dao()).With("author").FindPage(1, 1, "", "title")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	page.Items[0].Title = "updated"
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual := struct {
+		Items []map[string]any `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actualLen := len(actual.Items); actualLen != 1 {
+		t.Fatalf("expected 1 item, got %d", actualLen)
+	}
+	item := actual.Items[0]
+
+	dataset := []struct {
+		label    string
+		key      string
+		expected any
+	}{
+		{"id", "id", "book1"},
+		{"current value", "title", "updated"},
+		{"single relation", "author", "author1"},
+		{"multiple relation", "tags", []any{"tag1", "tag2"}},
+		{"collection id", "collectionId", "books0000000001"},
+		{"collection name", "collectionName", "books"},
+		{"expand", "expand", map[string]any{"author": map[string]any{
+			"id": "author1", "name": "foo", "collectionId": "authors00000001", "collectionName": "authors",
+			"created": item["expand"].(map[string]any)["author"].(map[string]any)["created"],
+			"updated": item["expand"].(map[string]any)["author"].(map[string]any)["updated"],
+		}}},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if !reflect.DeepEqual(item[data.key], data.expected) {
+				t.Errorf("expected %v, got %v", data.expected, item[data.key])
+			}
+		})
+	}
+
+	for _, key := range []string{"created", "updated"} {
+		if _, ok := item[key]; !ok {
+			t.Errorf("expected key %q, got %v", key, item)
+		}
+	}
+
+	for _, key := range []string{"Id", "Title"} {
+		if _, ok := item[key]; ok {
+			t.Errorf("expected no Go field name %q, got %v", key, item)
+		}
+	}
+}
+
+func TestPageJSONEmails(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	coll, err := testApp.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	coll.ListRule = pointer("")
+	if err := testApp.Dao().SaveCollection(coll); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	owner, err := testApp.Dao().FindRecordById("users", "4q1xlclmfloku33")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	dataset := []struct {
+		label    string
+		info     *models.RequestData
+		expected []string
+	}{
+		{"guest", nil, []string{"test3@example.com"}},
+		{"owner", &models.RequestData{AuthRecord: owner}, []string{"test@example.com", "test3@example.com"}},
+		{"admin", asAdmin, []string{"test@example.com", "test3@example.com", "test2@example.com"}},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			page, err := ParsePage[User](testApp.Dao(), data.info, "sort=id")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			emails := visibleEmails(t, page)
+			if !reflect.DeepEqual(emails, data.expected) {
+				t.Errorf("expected emails %v, got %v", data.expected, emails)
+			}
+		})
+	}
+}
+
+// visibleEmails returns the emails of the JSON representation of page.
+func visibleEmails(t *testing.T, page *Page[User]) []string {
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual := struct {
+		Items []map[string]any `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	emails := []string{}
+	for _, item := range actual.Items {
+		if email, ok := item["email"].(string); ok {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+func TestParsePageAccessRules(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	setRules := func(collection string, listRule *string, viewRule *string) {
+		coll, err := testApp.Dao().FindCollectionByNameOrId(collection)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		coll.ListRule = listRule
+		coll.ViewRule = viewRule
+		if err := testApp.Dao().SaveCollection(coll); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	if _, err := ParsePage[Book](testApp.Dao(), nil, "filter=title~'title'"); err == nil {
+		t.Errorf("expected admin only collection to deny guests, got nil error")
+	}
+
+	setRules("books", pointer("title != 'title2'"), pointer(""))
+	setRules("tags", pointer(""), pointer(""))
+
+	dataset := []struct {
+		label         string
+		info          *models.RequestData
+		query         string
+		expectedIds   []string
+		expectedNames []string
+	}{
+		{"list rule applied to guests", nil, "sort=title&expand=author,tags", []string{"book1", "book3"}, []string{"", ""}},
+		{"list rule applied to users", &models.RequestData{AuthRecord: models.NewRecord(Author{}.Collection())}, "sort=title&expand=author", []string{"book1", "book3"}, []string{"", ""}},
+		{"no rule for admins", asAdmin, "sort=title&expand=author", []string{"book1", "book2", "book3"}, []string{"foo", "foo", "foo"}},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			page, err := ParsePage[Book](testApp.Dao(), data.info, data.query)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			ids, names := []string{}, []string{}
+			for _, book := range page.Items {
+				ids = append(ids, book.Id)
+				names = append(names, book.Author.Name)
+			}
+
+			if !reflect.DeepEqual(ids, data.expectedIds) {
+				t.Errorf("expected books %v, got %v", data.expectedIds, ids)
+			}
+			if !reflect.DeepEqual(names, data.expectedNames) {
+				t.Errorf("expected author names %v, got %v", data.expectedNames, names)
+			}
+		})
+	}
+
+	page, err := ParsePage[Book](testApp.Dao(), nil, "sort=title&expand=tags")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if tags := page.Items[0].Tags; len(tags) != 2 || tags[0].Label != "qux" {
+		t.Errorf("expected viewable tags to be expanded, got %v", tags)
+	}
+
+	for _, query := range []string{"filter=@request.auth.id=''", "sort=@collection.authors.name"} {
+		if _, err := ParsePage[Book](testApp.Dao(), nil, query); err == nil {
+			t.Errorf("expected query %q to be denied to guests, got nil error", query)
+		}
+	}
+}
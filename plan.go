@@ -0,0 +1,287 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// encodeFunc converts the entity field value into the value of the column described by field.
+type encodeFunc func(field *schema.SchemaField, value reflect.Value) (any, error)
+
+// decodeFunc converts rawValue, the value of the column described by field in record, into the entity field value.
+type decodeFunc func(record *models.Record, field *schema.SchemaField, rawValue any, value reflect.Value, opts *mappingOptions) error
+
+// fieldPlan describes how a structure field is mapped onto a record column.
+type fieldPlan struct {
+	index      []int
+	name       string
+	typ        reflect.Type
+	columnName string
+	options    []string
+	omitEmpty  bool
+	readOnly   bool
+	custom     bool
+	encode     encodeFunc
+	decode     decodeFunc
+
+	// inlinedPointers are the indexes of the inlined structure pointers the field is promoted through,
+	// the outermost first.
+	inlinedPointers [][]int
+}
+
+// hasOption reports whether the orm tag of the field holds the given option.
+func (fp *fieldPlan) hasOption(option string) bool {
+	for _, o := range fp.options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// optionValue returns the value of the "key=value" option of the orm tag of the field.
+func (fp *fieldPlan) optionValue(key string) (string, bool) {
+	for _, o := range fp.options {
+		if value, ok := strings.CutPrefix(o, key+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// value returns the field of the structure value s. It returns false if the field is promoted
+// through a nil embedded pointer.
+func (fp *fieldPlan) value(s reflect.Value) (reflect.Value, bool) {
+	v, err := s.FieldByIndexErr(fp.index)
+	return v, err == nil
+}
+
+// settableValue returns the field of the structure value s, allocating the nil embedded pointers
+// it is promoted through.
+func (fp *fieldPlan) settableValue(s reflect.Value) reflect.Value {
+	v := s
+	for i, x := range fp.index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// typePlan is the mapping plan of a structure type, i.e. its fields tagged with an orm column name.
+type typePlan struct {
+	fields          []*fieldPlan
+	byColumn        map[string]*fieldPlan
+	tracked         *fieldPlan
+	inlinedPointers []*inlinedPointer
+}
+
+// inlinedPointer is a pointer to a structure inlined into the entity, which is nil when all its columns are blank.
+type inlinedPointer struct {
+	index   []int
+	columns []string
+}
+
+// field returns the plan of the field mapped onto columnName, or nil if there is none.
+func (p *typePlan) field(columnName string) *fieldPlan {
+	return p.byColumn[columnName]
+}
+
+// typePlans caches the *typePlan of every structure type already encoded or decoded.
+var typePlans sync.Map
+
+// planOf returns the mapping plan of the structure type t, building it on first use.
+func planOf(t reflect.Type) *typePlan {
+	if cached, ok := typePlans.Load(t); ok {
+		return cached.(*typePlan)
+	}
+
+	plan, _ := typePlans.LoadOrStore(t, buildTypePlan(t))
+	return plan.(*typePlan)
+}
+
+// buildTypePlan parses the orm tags of the structure type t once and for all.
+// The embedded structures without orm tag are flattened: their fields are mapped as promoted fields,
+// following the shadowing rules of Go. A field also shadows the deeper fields mapped onto the same column.
+// The fields of the structures tagged with the inline option are mapped onto prefixed columns, e.g.
+// `orm:"address,inline,prefix=addr_"` maps the street column of an Address onto addr_street.
+func buildTypePlan(t reflect.Type) *typePlan {
+	return buildInlinedTypePlan(t, nil)
+}
+
+// buildInlinedTypePlan builds the plan of the structure type t, inlined into the types of outer.
+func buildInlinedTypePlan(t reflect.Type, outer []reflect.Type) *typePlan {
+	plan := &typePlan{byColumn: map[string]*fieldPlan{}}
+	if t.Kind() != reflect.Struct {
+		return plan
+	}
+	outer = append(outer, t)
+
+	// the embedded fields tagged with a column name, mapped as a whole
+	tagged := [][]int{}
+
+	fields := []*fieldPlan{}
+	depths := map[*fieldPlan]int{}
+	add := func(fp *fieldPlan, depth int) {
+		fields = append(fields, fp)
+		depths[fp] = depth
+		if mapped, ok := plan.byColumn[fp.columnName]; !ok || depth < depths[mapped] {
+			plan.byColumn[fp.columnName] = fp
+		}
+	}
+
+	for _, field := range reflect.VisibleFields(t) {
+		if isPromotedFrom(field.Index, tagged) || !isReachable(t, field.Index) {
+			continue
+		}
+
+		if field.Anonymous && field.Type == trackedType && plan.tracked == nil {
+			plan.tracked = &fieldPlan{index: field.Index, name: field.Name, typ: field.Type}
+			continue
+		}
+
+		columnName, options := parseOrmTag(string(field.Tag))
+		if columnName == "" {
+			continue
+		}
+
+		if field.Anonymous {
+			tagged = append(tagged, field.Index)
+		}
+
+		fp := &fieldPlan{
+			index:      field.Index,
+			name:       field.Name,
+			typ:        field.Type,
+			columnName: columnName,
+			options:    options,
+			readOnly:   isReadOnlyColumn(columnName),
+		}
+		fp.omitEmpty = fp.hasOption("omitempty")
+		fp.encode, fp.decode, fp.custom = fieldConverters(field.Type)
+		if fp.hasOption("noDecimal") {
+			fp.encode, fp.decode = noDecimal(fp.encode, fp.decode)
+		}
+
+		inlined, ok := fp.inlinedType()
+		if !ok {
+			add(fp, len(field.Index))
+			continue
+		}
+
+		// recursive types can't be inlined into themselves
+		if containsType(outer, inlined) {
+			continue
+		}
+
+		prefix, ok := fp.optionValue("prefix")
+		if !ok {
+			prefix = columnName + "_"
+		}
+
+		for _, sub := range buildInlinedTypePlan(inlined, outer).fields {
+			subFp := *sub
+			subFp.index = append(append([]int{}, field.Index...), sub.index...)
+			subFp.name = field.Name + "." + sub.name
+			subFp.columnName = prefix + sub.columnName
+			subFp.readOnly = isReadOnlyColumn(subFp.columnName)
+			subFp.inlinedPointers = nil
+			if fp.typ.Kind() == reflect.Pointer {
+				subFp.inlinedPointers = append(subFp.inlinedPointers, field.Index)
+			}
+			for _, ptr := range sub.inlinedPointers {
+				subFp.inlinedPointers = append(subFp.inlinedPointers, append(append([]int{}, field.Index...), ptr...))
+			}
+			add(&subFp, len(field.Index))
+		}
+	}
+
+	for _, fp := range fields {
+		if depths[fp] == depths[plan.byColumn[fp.columnName]] {
+			plan.fields = append(plan.fields, fp)
+		}
+	}
+
+	plan.inlinedPointers = inlinedPointersOf(plan.fields)
+	return plan
+}
+
+// inlinedPointersOf returns the inlined structure pointers the fields are promoted through, with their
+// columns, the outermost first.
+func inlinedPointersOf(fields []*fieldPlan) []*inlinedPointer {
+	pointers := []*inlinedPointer{}
+	byIndex := map[string]*inlinedPointer{}
+
+	for _, fp := range fields {
+		for _, index := range fp.inlinedPointers {
+			key := fmt.Sprint(index)
+			ptr, ok := byIndex[key]
+			if !ok {
+				ptr = &inlinedPointer{index: index}
+				byIndex[key] = ptr
+				pointers = append(pointers, ptr)
+			}
+			ptr.columns = append(ptr.columns, fp.columnName)
+		}
+	}
+
+	sort.SliceStable(pointers, func(i, j int) bool {
+		return len(pointers[i].index) < len(pointers[j].index)
+	})
+	return pointers
+}
+
+// inlinedType returns the structure type whose fields are inlined by the field, i.e. the type of a structure
+// or of a pointer to a structure tagged with the inline option. It returns false if the field is not inlined.
+func (fp *fieldPlan) inlinedType() (reflect.Type, bool) {
+	if !fp.hasOption("inline") || fp.custom {
+		return nil, false
+	}
+
+	t := fp.typ
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t, t.Kind() == reflect.Struct
+}
+
+// containsType reports whether t is one of types.
+func containsType(types []reflect.Type, t reflect.Type) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// isPromotedFrom reports whether the field at index is promoted from one of the embedded fields at embeds.
+func isPromotedFrom(index []int, embeds [][]int) bool {
+	for _, embed := range embeds {
+		if len(index) > len(embed) && reflect.DeepEqual(index[:len(embed)], embed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isReachable reports whether the field at index of the structure type t can be set, i.e. it is not
+// promoted through an unexported embedded pointer, which can't be allocated.
+func isReachable(t reflect.Type, index []int) bool {
+	for i := 1; i < len(index); i++ {
+		if embed := t.FieldByIndex(index[:i]); embed.Type.Kind() == reflect.Pointer && !embed.IsExported() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanOf(t *testing.T) {
+	plan := planOf(reflect.TypeOf(EntityWithSystemFields{}))
+
+	expectedColumns := []string{"id", "title", "created", "updated", "collectionId", "collectionName"}
+	if actualLen := len(plan.fields); actualLen != len(expectedColumns) {
+		t.Fatalf("expected %d fields, got %d", len(expectedColumns), actualLen)
+	}
+
+	for i, columnName := range expectedColumns {
+		fp := plan.fields[i]
+		if fp.columnName != columnName {
+			t.Errorf("expected column %q, got %q", columnName, fp.columnName)
+		}
+		if !reflect.DeepEqual(fp.index, []int{i}) {
+			t.Errorf("expected index [%d], got %v", i, fp.index)
+		}
+		if plan.field(columnName) != fp {
+			t.Errorf("expected %q to be indexed by column name", columnName)
+		}
+	}
+
+	if !plan.field("created").readOnly || plan.field("title").readOnly {
+		t.Errorf("expected only system fields to be read-only")
+	}
+}
+
+func TestPlanOfIsCached(t *testing.T) {
+	entityType := reflect.TypeOf(EntityWithAllPBTypes{})
+
+	if planOf(entityType) != planOf(entityType) {
+		t.Errorf("expected the same plan to be returned")
+	}
+}
+
+func TestPlanOfWithOptions(t *testing.T) {
+	plan := planOf(reflect.TypeOf(struct {
+		Untagged string
+		Ignored  string `json:"ignored"`
+		Text     string `orm:"text,omitempty,foo"`
+	}{}))
+
+	if actualLen := len(plan.fields); actualLen != 1 {
+		t.Fatalf("expected 1 field, got %d", actualLen)
+	}
+
+	fp := plan.field("text")
+	if fp == nil || !fp.omitEmpty || !fp.hasOption("foo") || fp.hasOption("bar") {
+		t.Errorf("unexpected field plan %+v", fp)
+	}
+}
+
+func TestPlanOfEmbeddedStructs(t *testing.T) {
+	type inner struct {
+		Name string `orm:"inner_name"`
+	}
+
+	type Named struct {
+		Name  string `orm:"name"`
+		Title string `orm:"title"`
+	}
+
+	type Shared struct {
+		Tag string `orm:"tag"`
+	}
+
+	plan := planOf(reflect.TypeOf(struct {
+		BaseEntity
+		*Named
+		*inner
+		Shared `orm:"shared"`
+		Name   string `orm:"label"`
+		Other  string `orm:"title"`
+	}{}))
+
+	expectedColumns := map[string][]int{
+		"id":      {0, 0},
+		"created": {0, 1},
+		"updated": {0, 2},
+		"shared":  {3},
+		"label":   {4},
+		"title":   {5},
+	}
+	if actualLen := len(plan.fields); actualLen != len(expectedColumns) {
+		t.Fatalf("expected %d fields, got %d", len(expectedColumns), actualLen)
+	}
+
+	for columnName, expectedIndex := range expectedColumns {
+		fp := plan.field(columnName)
+		if fp == nil {
+			t.Errorf("expected column %q to be mapped", columnName)
+			continue
+		}
+		if !reflect.DeepEqual(fp.index, expectedIndex) {
+			t.Errorf("expected %q at index %v, got %v", columnName, expectedIndex, fp.index)
+		}
+	}
+
+	for _, columnName := range []string{"name", "inner_name", "tag"} {
+		if plan.field(columnName) != nil {
+			t.Errorf("expected column %q not to be mapped", columnName)
+		}
+	}
+}
+
+func TestPlanOfInlineStructs(t *testing.T) {
+	plan := planOf(reflect.TypeOf(EntityWithInlineStructs{}))
+
+	expectedColumns := map[string]string{
+		"id":              "Id",
+		"name":            "Name",
+		"addr_street":     "Address.Street",
+		"addr_city":       "Address.City",
+		"addr_geo_lat":    "Address.Geo.Lat",
+		"addr_geo_lng":    "Address.Geo.Lng",
+		"billing_street":  "Billing.Street",
+		"billing_city":    "Billing.City",
+		"billing_geo_lat": "Billing.Geo.Lat",
+		"billing_geo_lng": "Billing.Geo.Lng",
+	}
+	if actualLen := len(plan.fields); actualLen != len(expectedColumns) {
+		t.Fatalf("expected %d fields, got %d", len(expectedColumns), actualLen)
+	}
+
+	for columnName, expectedName := range expectedColumns {
+		fp := plan.field(columnName)
+		if fp == nil {
+			t.Errorf("expected column %q to be mapped", columnName)
+			continue
+		}
+		if fp.name != expectedName {
+			t.Errorf("expected %q to be mapped by %s, got %s", columnName, expectedName, fp.name)
+		}
+	}
+
+	if fp := plan.field("addr_geo_lat"); !reflect.DeepEqual(fp.index, []int{2, 2, 0}) {
+		t.Errorf("expected index [2 2 0], got %v", fp.index)
+	}
+
+	if actualLen := len(plan.inlinedPointers); actualLen != 1 {
+		t.Fatalf("expected 1 inlined pointer, got %d", actualLen)
+	}
+	expectedPointer := &inlinedPointer{
+		index:   []int{3},
+		columns: []string{"billing_street", "billing_city", "billing_geo_lat", "billing_geo_lng"},
+	}
+	if !reflect.DeepEqual(plan.inlinedPointers[0], expectedPointer) {
+		t.Errorf("expected %v, got %v", expectedPointer, plan.inlinedPointers[0])
+	}
+}
+
+type recursiveInline struct {
+	Name string           `orm:"name"`
+	Next *recursiveInline `orm:"next,inline"`
+}
+
+func TestPlanOfRecursiveInline(t *testing.T) {
+	plan := planOf(reflect.TypeOf(recursiveInline{}))
+
+	if actualLen := len(plan.fields); actualLen != 1 || plan.field("name") == nil {
+		t.Errorf("expected only the name field, got %d fields", actualLen)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		entity := EntityWithAllPBTypes{}
+		if err := Decode(recordExample, &entity); err != nil {
+			b.Fatalf("expected no error, got %v", err)
+		}
+	}
+}
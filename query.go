@@ -0,0 +1,232 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// QueryBuilder is a fluent typed query on the collection of the entity T.
+//
+// Column names given to Where and OrderBy must match both an orm tag of T and a field
+// of the collection schema, otherwise the query fails before reaching the database.
+type QueryBuilder[T Entity] struct {
+	dao     *daos.Dao
+	coll    *models.Collection
+	exprs   []dbx.Expression
+	orders  []string
+	limit   int64
+	offset  int64
+	expands []string
+	opts    []Option
+	err     error
+}
+
+// Query returns a new QueryBuilder of T bound to the given dao, decoding the found entities with opts.
+//
+// Example:
+//
+//	posts, err := orm.Query[Post](dao).
+//		Where("title", "~", "foo").
+//		OrderBy("-published_at").
+//		Limit(10).
+//		All()
+func Query[T Entity](dao *daos.Dao, opts ...Option) *QueryBuilder[T] {
+	return &QueryBuilder[T]{dao: dao, limit: -1, opts: opts}
+}
+
+// Where appends a condition on column, joined with AND to the previous ones.
+//
+// Supported operators are =, !=, >, >=, <, <=, ~ (like) and !~ (not like).
+func (q *QueryBuilder[T]) Where(column string, operator string, value any) *QueryBuilder[T] {
+	if !q.checkColumn(column) {
+		return q
+	}
+
+	param := fmt.Sprintf("p%d", len(q.exprs))
+	placeholder := fmt.Sprintf("[[%s]] %%s {:%s}", column, param)
+
+	switch operator {
+	case "=", "!=", ">", ">=", "<", "<=":
+		sqlOperator := operator
+		if operator == "!=" {
+			sqlOperator = "<>"
+		}
+		q.exprs = append(q.exprs, dbx.NewExp(fmt.Sprintf(placeholder, sqlOperator), dbx.Params{param: value}))
+	case "~":
+		q.exprs = append(q.exprs, dbx.Like(column, fmt.Sprint(value)))
+	case "!~":
+		q.exprs = append(q.exprs, dbx.NotLike(column, fmt.Sprint(value)))
+	default:
+		q.err = fmt.Errorf("unsupported operator %q on column %q", operator, column)
+	}
+
+	return q
+}
+
+// WhereIn appends a condition matching column against any of the given values.
+func (q *QueryBuilder[T]) WhereIn(column string, values ...any) *QueryBuilder[T] {
+	if !q.checkColumn(column) {
+		return q
+	}
+
+	q.exprs = append(q.exprs, dbx.In(column, values...))
+	return q
+}
+
+// WhereExpr appends a raw dbx expression, which is not checked against the entity columns.
+func (q *QueryBuilder[T]) WhereExpr(expr dbx.Expression) *QueryBuilder[T] {
+	if expr != nil {
+		q.exprs = append(q.exprs, expr)
+	}
+	return q
+}
+
+// OrderBy appends sorting columns. Similarly to PocketBase sort syntax,
+// a column prefixed with "-" is sorted in descending order.
+func (q *QueryBuilder[T]) OrderBy(columns ...string) *QueryBuilder[T] {
+	for _, column := range columns {
+		direction := "ASC"
+		if strings.HasPrefix(column, "-") {
+			column = strings.TrimPrefix(column, "-")
+			direction = "DESC"
+		} else {
+			column = strings.TrimPrefix(column, "+")
+		}
+
+		if !q.checkColumn(column) {
+			return q
+		}
+
+		q.orders = append(q.orders, fmt.Sprintf("[[%s]] %s", column, direction))
+	}
+	return q
+}
+
+// With eager loads the given relation paths (e.g. "author", "tags.owner")
+// into the nested entities of the returned ones.
+func (q *QueryBuilder[T]) With(paths ...string) *QueryBuilder[T] {
+	q.expands = append(q.expands, paths...)
+	return q
+}
+
+// Limit sets the maximum number of returned entities, a negative value means no limit.
+func (q *QueryBuilder[T]) Limit(limit int64) *QueryBuilder[T] {
+	q.limit = limit
+	return q
+}
+
+// Offset sets the number of entities skipped before the first returned one.
+func (q *QueryBuilder[T]) Offset(offset int64) *QueryBuilder[T] {
+	q.offset = offset
+	return q
+}
+
+// All executes the query and returns the decoded entities.
+func (q *QueryBuilder[T]) All() ([]*T, error) {
+	query, err := q.build()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*models.Record{}
+	if err := query.All(&records); err != nil {
+		return nil, fmt.Errorf("could not execute query: %w", err)
+	}
+
+	return decodeRecords[T](q.dao, records, q.expands, q.opts...)
+}
+
+// One executes the query and returns the first decoded entity.
+func (q *QueryBuilder[T]) One() (*T, error) {
+	query, err := q.build()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.Record{}
+	if err := query.Limit(1).One(record); err != nil {
+		return nil, fmt.Errorf("could not execute query: %w", err)
+	}
+
+	entities, err := decodeRecords[T](q.dao, []*models.Record{record}, q.expands, q.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return entities[0], nil
+}
+
+// build returns the dbx query matching the current state of q.
+func (q *QueryBuilder[T]) build() (*dbx.SelectQuery, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	coll, err := q.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	query := q.dao.RecordQuery(coll)
+	for _, expr := range q.exprs {
+		query.AndWhere(expr)
+	}
+
+	if len(q.orders) > 0 {
+		query.OrderBy(q.orders...)
+	}
+
+	return query.Limit(q.limit).Offset(q.offset), nil
+}
+
+// collection returns the collection of T, fetching it only once per query.
+func (q *QueryBuilder[T]) collection() (*models.Collection, error) {
+	if q.coll != nil {
+		return q.coll, nil
+	}
+
+	if q.dao == nil {
+		return nil, fmt.Errorf("could not query: dao is nil")
+	}
+
+	var zeroValue T
+	coll, err := findCollection(q.dao, zeroValue.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("could not get entity collection: %w", err)
+	}
+
+	q.coll = coll
+	return coll, nil
+}
+
+// checkColumn records an error on q if column is not mapped by T onto its collection.
+// It returns whether column is valid.
+func (q *QueryBuilder[T]) checkColumn(column string) bool {
+	if q.err != nil {
+		return false
+	}
+
+	entityType := reflect.TypeOf((*T)(nil)).Elem()
+	if !hasOrmName(entityType, column) {
+		q.err = fmt.Errorf("unknown column %q: no field of %s is tagged with it", column, entityType)
+		return false
+	}
+
+	coll, err := q.collection()
+	if err != nil {
+		q.err = err
+		return false
+	}
+
+	if fieldFromColumnName(coll.Schema, column) == nil {
+		q.err = fmt.Errorf("unknown column %q: not part of collection %q", column, coll.Name)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package orm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/tests"
+)
+
+func setupQueryTests() (testApp *tests.TestApp, err error) {
+	testApp, err = setupEncodeTests()
+	if err != nil {
+		return nil, err
+	}
+
+	repository := NewRepository[EntityWithAllPBTypes](testApp.Dao())
+	for i, text := range []string{"foo", "bar", "baz", "qux"} {
+		entity := EntityWithAllPBTypes{Text: text, NumberInt: i}
+		if err := repository.Save(&entity); err != nil {
+			testApp.Cleanup()
+			return nil, fmt.Errorf("could not save entity: %w", err)
+		}
+	}
+
+	return
+}
+
+func TestQueryAll(t *testing.T) {
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dataset := []struct {
+		label    string
+		query    *QueryBuilder[EntityWithAllPBTypes]
+		expected []string
+	}{
+		{
+			label:    "without condition",
+			query:    Query[EntityWithAllPBTypes](testApp.Dao()).OrderBy("text"),
+			expected: []string{"bar", "baz", "foo", "qux"},
+		},
+		{
+			label:    "with equality",
+			query:    Query[EntityWithAllPBTypes](testApp.Dao()).Where("text", "=", "baz"),
+			expected: []string{"baz"},
+		},
+		{
+			label:    "with comparison and descending order",
+			query:    Query[EntityWithAllPBTypes](testApp.Dao()).Where("number_int", ">=", 2).OrderBy("-number_int"),
+			expected: []string{"qux", "baz"},
+		},
+		{
+			label:    "with like",
+			query:    Query[EntityWithAllPBTypes](testApp.Dao()).Where("text", "~", "ba").OrderBy("text"),
+			expected: []string{"bar", "baz"},
+		},
+		{
+			label:    "with in",
+			query:    Query[EntityWithAllPBTypes](testApp.Dao()).WhereIn("text", "foo", "qux").OrderBy("text"),
+			expected: []string{"foo", "qux"},
+		},
+		{
+			label:    "with limit and offset",
+			query:    Query[EntityWithAllPBTypes](testApp.Dao()).OrderBy("number_int").Limit(2).Offset(1),
+			expected: []string{"bar", "baz"},
+		},
+	}
+
+	for _, tt := range dataset {
+		t.Run(tt.label, func(t *testing.T) {
+			entities, err := tt.query.All()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			actual := make([]string, len(entities))
+			for i, entity := range entities {
+				actual[i] = entity.Text
+			}
+
+			if fmt.Sprint(actual) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestQueryOne(t *testing.T) {
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity, err := Query[EntityWithAllPBTypes](testApp.Dao()).Where("number_int", "=", 1).One()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if entity.Text != "bar" {
+		t.Errorf("expected %q, got %q", "bar", entity.Text)
+	}
+}
+
+func TestQueryWithUnknownColumn(t *testing.T) {
+	testApp, err := setupQueryTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	dataset := []struct {
+		label string
+		query *QueryBuilder[EntityWithAllPBTypes]
+	}{
+		{
+			label: "with untagged column",
+			query: Query[EntityWithAllPBTypes](testApp.Dao()).Where("txt", "=", "foo"),
+		},
+		{
+			label: "with unknown order column",
+			query: Query[EntityWithAllPBTypes](testApp.Dao()).OrderBy("-txt"),
+		},
+		{
+			label: "with unsupported operator",
+			query: Query[EntityWithAllPBTypes](testApp.Dao()).Where("text", "?=", "foo"),
+		},
+	}
+
+	for _, tt := range dataset {
+		t.Run(tt.label, func(t *testing.T) {
+			if _, err := tt.query.All(); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
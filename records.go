@@ -1,12 +1,29 @@
 package orm
 
 import (
+	"reflect"
+	"time"
+
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 var pbMetadata = []string{schema.FieldNameCreated, schema.FieldNameUpdated}
 
+// readOnlyColumns are the PocketBase system columns that can be decoded into entities but are never encoded.
+var readOnlyColumns = []string{
+	schema.FieldNameCreated,
+	schema.FieldNameUpdated,
+	schema.FieldNameCollectionId,
+	schema.FieldNameCollectionName,
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	dateTimeType = reflect.TypeOf(types.DateTime{})
+)
+
 // RecordsColumnValueMap returns business column value map
 // (i.e. r.ColumnValueMap without PocketBase metadata such as created and updated).
 func RecordsColumnValueMap(r *models.Record) map[string]interface{} {
@@ -22,14 +39,168 @@ func RecordsColumnValueMap(r *models.Record) map[string]interface{} {
 }
 
 // fieldFromColumnName returns the schema.SchemaField according to columnName of the given schema s.
-// It also manages the case where the column name represents the row identifier (id).
+// It also manages the case where the column name represents the row identifier (id)
+// or one of the creation and update dates (created and updated).
 func fieldFromColumnName(s schema.Schema, columnName string) *schema.SchemaField {
-	if columnName == schema.FieldNameId {
+	switch columnName {
+	case schema.FieldNameId:
 		return &schema.SchemaField{
 			Name: schema.FieldNameId,
 			Type: schema.FieldTypeText,
 		}
+	case schema.FieldNameCreated, schema.FieldNameUpdated:
+		return &schema.SchemaField{
+			Name: columnName,
+			Type: schema.FieldTypeDate,
+		}
 	}
 
 	return s.GetFieldByName(columnName)
 }
+
+// stringSliceFromRawValue returns the strings of a multiple values column raw value
+// (i.e. a types.JsonArray[string] or a []string).
+func stringSliceFromRawValue(rawValue any) ([]string, bool) {
+	switch v := rawValue.(type) {
+	case types.JsonArray[string]:
+		return append([]string{}, v...), true
+	case []string:
+		return append([]string{}, v...), true
+	}
+	return nil, false
+}
+
+// isNullablePointer reports whether a field of type t is a nullable pointer on the column fieldType,
+// i.e. a pointer to a value mapped onto the column, nil when the column is blank (see isBlankColumnValue).
+// The entity pointers on relations are mapped as a whole, they are not nullable pointers.
+//
+// PocketBase number and bool columns are never null, a nil pointer being stored as 0 or false: on these
+// columns, nil can't be told from the zero value and is decoded for it. Validate reports such fields.
+func isNullablePointer(fieldType *schema.SchemaField, t reflect.Type) bool {
+	if t.Kind() != reflect.Pointer {
+		return false
+	}
+
+	if fieldType.Type == schema.FieldTypeRelation {
+		return !isEntityPointer(t)
+	}
+	return true
+}
+
+// isBlankRawValue reports whether rawValue is null or the blank value of a text, date, JSON or multiple
+// values column. Numbers and bools are never blank, PocketBase storing them as non-null columns.
+func isBlankRawValue(rawValue any) bool {
+	switch v := rawValue.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case types.DateTime:
+		return v.IsZero()
+	case types.JsonRaw:
+		return len(v) == 0 || string(v) == "null"
+	case types.JsonArray[string]:
+		return len(v) == 0
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// isBlankColumnValue reports whether rawValue is the blank value of the column of fieldType, i.e. the value
+// of a nil pointer field (see isBlankRawValue) or the zero value PocketBase stores for it.
+func isBlankColumnValue(fieldType *schema.SchemaField, rawValue any) bool {
+	return isBlankRawValue(rawValue) || reflect.DeepEqual(fieldType.PrepareValue(rawValue), fieldType.PrepareValue(nil))
+}
+
+// isReadOnlyColumn reports whether columnName is a system column that must never be encoded.
+func isReadOnlyColumn(columnName string) bool {
+	for _, readOnlyColumn := range readOnlyColumns {
+		if columnName == readOnlyColumn {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyColumnType returns the PocketBase type of the read-only system column columnName.
+func readOnlyColumnType(columnName string) string {
+	switch columnName {
+	case schema.FieldNameCreated, schema.FieldNameUpdated:
+		return schema.FieldTypeDate
+	}
+	return schema.FieldTypeText
+}
+
+// checkReadOnlyColumn returns an error if a field of type t can't hold the read-only system column columnName,
+// i.e. a date held by a time.Time, a types.DateTime, pointers to them or a string, or a collection id or name
+// held by a string.
+func checkReadOnlyColumn(columnName string, t reflect.Type) error {
+	if readOnlyColumnType(columnName) == schema.FieldTypeDate {
+		dateType := t
+		if dateType.Kind() == reflect.Pointer {
+			dateType = dateType.Elem()
+		}
+		if dateType == timeType || dateType == dateTimeType || t.Kind() == reflect.String {
+			return nil
+		}
+	} else if t.Kind() == reflect.String {
+		return nil
+	}
+	return kindError(readOnlyColumnType(columnName), t)
+}
+
+// decodeReadOnlyColumn sets entityField to the value of the read-only system column columnName of record,
+// the dates being set in loc. It returns an error if entityField can't hold the column, see checkReadOnlyColumn.
+func decodeReadOnlyColumn(record *models.Record, columnName string, entityField reflect.Value, loc *time.Location) error {
+	if err := checkReadOnlyColumn(columnName, entityField.Type()); err != nil {
+		return err
+	}
+
+	switch columnName {
+	case schema.FieldNameCreated:
+		setDateTime(entityField, record.Created, loc)
+	case schema.FieldNameUpdated:
+		setDateTime(entityField, record.Updated, loc)
+	case schema.FieldNameCollectionId:
+		entityField.SetString(record.Collection().Id)
+	case schema.FieldNameCollectionName:
+		entityField.SetString(record.Collection().Name)
+	}
+	return nil
+}
+
+// setDateTime sets entityField, which can be a time.Time, a types.DateTime, pointers to them or a string, to dt.
+// Pointers are set to nil when dt is zero. A time.Time is set in loc, or in UTC if loc is nil.
+func setDateTime(entityField reflect.Value, dt types.DateTime, loc *time.Location) {
+	fieldType := entityField.Type()
+	if fieldType.Kind() == reflect.Pointer {
+		if dt.IsZero() {
+			entityField.Set(reflect.Zero(fieldType))
+			return
+		}
+		entityField.Set(reflect.New(fieldType.Elem()))
+		entityField = entityField.Elem()
+		fieldType = fieldType.Elem()
+	}
+
+	switch {
+	case fieldType == timeType:
+		if dt.IsZero() {
+			entityField.Set(reflect.Zero(timeType))
+			return
+		}
+		if loc == nil {
+			loc = time.UTC
+		}
+		entityField.Set(reflect.ValueOf(dt.Time().In(loc)))
+	case fieldType == dateTimeType:
+		entityField.Set(reflect.ValueOf(dt))
+	case fieldType.Kind() == reflect.String:
+		if dt.IsZero() {
+			entityField.SetString("")
+			return
+		}
+		entityField.SetString(dt.String())
+	}
+}
@@ -0,0 +1,141 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// Ref references the entity of a single relation column by its id. Entity holds the referenced entity
+// once loaded, by Load or by decoding a record whose relation is expanded (see Repository.With).
+type Ref[T Entity] struct {
+	Id     string
+	Entity *T
+}
+
+// RefTo returns a reference to entity.
+func RefTo[T Entity](entity *T) Ref[T] {
+	if entity == nil {
+		return Ref[T]{}
+	}
+	return Ref[T]{Id: entityId(reflect.ValueOf(entity).Elem()), Entity: entity}
+}
+
+// id returns the id of the referenced entity, taken from Entity when Id is empty.
+func (r Ref[T]) id() string {
+	if r.Id == "" && r.Entity != nil {
+		return entityId(reflect.ValueOf(r.Entity).Elem())
+	}
+	return r.Id
+}
+
+// IsZero reports whether r references no entity.
+func (r Ref[T]) IsZero() bool {
+	return r.id() == ""
+}
+
+// Load fetches the referenced entity with dao, sets it into r.Entity and returns it.
+func (r *Ref[T]) Load(dao *daos.Dao, opts ...Option) (*T, error) {
+	entities, err := loadRefs[T](dao, []string{r.id()}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Entity = entities[0]
+	return r.Entity, nil
+}
+
+// Refs references the entities of a multiple relation column.
+type Refs[T Entity] []Ref[T]
+
+// RefsTo returns the references to entities.
+func RefsTo[T Entity](entities ...*T) Refs[T] {
+	refs := make(Refs[T], len(entities))
+	for i, entity := range entities {
+		refs[i] = RefTo(entity)
+	}
+	return refs
+}
+
+// Ids returns the ids of the referenced entities.
+func (r Refs[T]) Ids() []string {
+	ids := make([]string, len(r))
+	for i, ref := range r {
+		ids[i] = ref.id()
+	}
+	return ids
+}
+
+// Load fetches the referenced entities with dao, sets them into the Entity of every reference
+// and returns them in the order of the references.
+func (r Refs[T]) Load(dao *daos.Dao, opts ...Option) ([]*T, error) {
+	entities, err := loadRefs[T](dao, r.Ids(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range r {
+		r[i].Entity = entities[i]
+	}
+	return entities, nil
+}
+
+// relatedEntity implements the reference interface.
+func (Ref[T]) relatedEntity() Entity {
+	var zeroValue T
+	return zeroValue
+}
+
+// relatedEntity implements the reference interface.
+func (Refs[T]) relatedEntity() Entity {
+	var zeroValue T
+	return zeroValue
+}
+
+// reference is implemented by Ref and Refs, returning the zero value of the entity they reference.
+type reference interface {
+	relatedEntity() Entity
+}
+
+var referenceInterface = reflect.TypeOf((*reference)(nil)).Elem()
+
+// isRef reports whether t is a Ref type.
+func isRef(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.Implements(referenceInterface)
+}
+
+// isRefs reports whether t is a Refs type.
+func isRefs(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Implements(referenceInterface)
+}
+
+// loadRefs returns the entities of T identified by ids, in the same order.
+func loadRefs[T Entity](dao *daos.Dao, ids []string, opts ...Option) ([]*T, error) {
+	if dao == nil {
+		return nil, fmt.Errorf("could not load references: dao is nil")
+	}
+
+	var zeroValue T
+	records, err := dao.FindRecordsByIds(zeroValue.CollectionName(), ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not find records %q: %w", ids, err)
+	}
+
+	recordsById := make(map[string]*models.Record, len(records))
+	for _, record := range records {
+		recordsById[record.Id] = record
+	}
+
+	ordered := make([]*models.Record, len(ids))
+	for i, id := range ids {
+		record, ok := recordsById[id]
+		if !ok {
+			return nil, fmt.Errorf("could not find record %q of collection %q", id, zeroValue.CollectionName())
+		}
+		ordered[i] = record
+	}
+
+	return decodeRecords[T](dao, ordered, nil, opts...)
+}
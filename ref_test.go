@@ -0,0 +1,130 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func TestRefs(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	books := NewRepository[BookWithRefs](testApp.Dao(), Strict())
+
+	book := BookWithRefs{
+		Title:  "refs",
+		Author: RefTo(&Author{Id: "author2"}),
+		Tags:   Refs[Tag]{{Id: "tag2"}, {Id: "tag1"}},
+	}
+	if err := books.Save(&book); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := books.FindById(book.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := BookWithRefs{Id: book.Id, Title: "refs", Author: Ref[Author]{Id: "author2"}, Tags: Refs[Tag]{{Id: "tag2"}, {Id: "tag1"}}}
+	if !reflect.DeepEqual(*actual, expected) {
+		t.Errorf("expected references without entities %v, got %v", expected, *actual)
+	}
+
+	expanded, err := books.With("author", "tags").FindById(book.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if expanded.Author.Entity == nil || expanded.Author.Entity.Name != "bar" {
+		t.Errorf("expected expanded author bar, got %v", expanded.Author.Entity)
+	}
+	if len(expanded.Tags) != 2 || expanded.Tags[0].Entity == nil || expanded.Tags[0].Entity.Label != "quux" {
+		t.Errorf("expected expanded tags, got %v", expanded.Tags)
+	}
+}
+
+func TestRefLoad(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	ref := Ref[Author]{Id: "author1"}
+	author, err := ref.Load(testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if author.Name != "foo" || ref.Entity != author {
+		t.Errorf("expected author foo to be loaded, got %v", ref.Entity)
+	}
+
+	refs := Refs[Tag]{{Id: "tag2"}, {Id: "tag1"}}
+	tags, err := refs.Load(testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(tags) != 2 || tags[0].Label != "quux" || tags[1].Label != "qux" || refs[1].Entity != tags[1] {
+		t.Errorf("expected tags quux and qux to be loaded in order, got %v", tags)
+	}
+
+	if _, err := (&Ref[Author]{Id: "missing"}).Load(testApp.Dao()); err == nil {
+		t.Errorf("expected missing record error, got nil")
+	}
+}
+
+func TestRefsRelationOptions(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	tooManyTags := Refs[Tag]{}
+	for i := 0; i < 11; i++ {
+		tooManyTags = append(tooManyTags, Ref[Tag]{Id: fmt.Sprintf("tag%d", i)})
+	}
+
+	if _, err := Encode(&BookWithRefs{Tags: tooManyTags}, testApp.Dao(), Strict()); err == nil {
+		t.Errorf("expected max select error, got nil")
+	}
+
+	if _, err := Encode(&BookWithTagRefAsAuthor{Author: Ref[Tag]{Id: "tag1"}}, testApp.Dao(), Strict()); err == nil {
+		t.Errorf("expected related collection error, got nil")
+	}
+
+	if err := Validate[BookWithTagRefAsAuthor](testApp.Dao()); err == nil {
+		t.Errorf("expected validation error, got nil")
+	}
+
+	if err := Validate[BookWithRefs](testApp.Dao()); err != nil {
+		t.Errorf("expected no validation error, got %v", err)
+	}
+}
+
+func TestBuildCollectionsWithRefs(t *testing.T) {
+	colls, err := BuildCollections(nil, Author{}, Tag{}, BookWithRefs{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	authors, tags, books := colls[0], colls[1], colls[2]
+
+	author := books.Schema.GetFieldByName("author").Options.(*schema.RelationOptions)
+	if author.CollectionId != authors.Id || author.IsMultiple() {
+		t.Errorf("expected single relation to authors, got %v", author)
+	}
+
+	bookTags := books.Schema.GetFieldByName("tags").Options.(*schema.RelationOptions)
+	if bookTags.CollectionId != tags.Id || !bookTags.IsMultiple() {
+		t.Errorf("expected multiple relation to tags, got %v", bookTags)
+	}
+}
@@ -0,0 +1,290 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var entityInterface = reflect.TypeOf((*Entity)(nil)).Elem()
+
+// isEntityPointer reports whether t is a pointer to an Entity structure (e.g. *Author).
+func isEntityPointer(t reflect.Type) bool {
+	return t.Kind() == reflect.Pointer &&
+		t.Elem().Kind() == reflect.Struct &&
+		t.Implements(entityInterface)
+}
+
+// isEntityPointerSlice reports whether t is a slice of pointers to an Entity structure (e.g. []*Tag).
+func isEntityPointerSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && isEntityPointer(t.Elem())
+}
+
+// entityId returns the value of the field tagged as id of the entity structure s.
+func entityId(s reflect.Value) string {
+	fp := planOf(s.Type()).field(schema.FieldNameId)
+	if fp == nil {
+		return ""
+	}
+
+	if field, ok := fp.value(s); ok && field.Kind() == reflect.String {
+		return field.String()
+	}
+	return ""
+}
+
+// setEntityId sets the field tagged as id of the entity structure s.
+func setEntityId(s reflect.Value, id string) {
+	fp := planOf(s.Type()).field(schema.FieldNameId)
+	if fp == nil {
+		return
+	}
+
+	if field := fp.settableValue(s); field.Kind() == reflect.String {
+		field.SetString(id)
+	}
+}
+
+// isRelated reports whether t references a related entity, i.e. t is an entity pointer or a Ref.
+func isRelated(t reflect.Type) bool {
+	return isEntityPointer(t) || isRef(t)
+}
+
+// isRelatedSlice reports whether t references related entities, i.e. t is a slice of entity pointers or a Refs.
+func isRelatedSlice(t reflect.Type) bool {
+	return isEntityPointerSlice(t) || isRefs(t)
+}
+
+// relatedType returns the structure type of the entities referenced by t, an entity pointer, a Ref,
+// or a slice of them. It returns nil if t references no entity.
+func relatedType(t reflect.Type) reflect.Type {
+	switch {
+	case isRef(t), isRefs(t):
+		return reflect.TypeOf(reflect.Zero(t).Interface().(reference).relatedEntity())
+	case isEntityPointerSlice(t):
+		return t.Elem().Elem()
+	case isEntityPointer(t):
+		return t.Elem()
+	}
+	return nil
+}
+
+// relatedId returns the id of the entity referenced by item, an entity pointer or a Ref.
+// It returns an empty string if item references no entity.
+func relatedId(item reflect.Value) string {
+	if isRef(item.Type()) {
+		return item.Interface().(interface{ id() string }).id()
+	}
+
+	if item.IsNil() {
+		return ""
+	}
+	return entityId(item.Elem())
+}
+
+// relatedEntityIds returns the ids of the entities referenced by entityField, which is either
+// an entity pointer, a Ref or a slice of them. Nil entities and empty references are skipped.
+func relatedEntityIds(entityField reflect.Value) []string {
+	if entityField.Kind() != reflect.Slice {
+		if id := relatedId(entityField); id != "" {
+			return []string{id}
+		}
+		return nil
+	}
+
+	ids := make([]string, 0, entityField.Len())
+	for i := 0; i < entityField.Len(); i++ {
+		if id := relatedId(entityField.Index(i)); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// checkRelatedCollection returns an error if the entities referenced by the fields of type t don't belong
+// to the related collection of the relation column fieldType. Fields referencing no entity type (e.g. ids)
+// can't be checked.
+func checkRelatedCollection(dao *daos.Dao, fieldType *schema.SchemaField, t reflect.Type) error {
+	return newRelatedCollections(dao).check(fieldType, t)
+}
+
+// relatedCollections checks the related collections of relation columns, looking up the collection of
+// each related entity type once, so encoding many entities doesn't query a collection per relation field.
+type relatedCollections struct {
+	dao *daos.Dao
+	ids map[reflect.Type]string
+}
+
+func newRelatedCollections(dao *daos.Dao) *relatedCollections {
+	return &relatedCollections{dao: dao, ids: make(map[reflect.Type]string)}
+}
+
+// check is checkRelatedCollection, with the collection ids of the related types already looked up reused.
+func (rc *relatedCollections) check(fieldType *schema.SchemaField, t reflect.Type) error {
+	if isNullablePointer(fieldType, t) {
+		t = t.Elem()
+	}
+
+	related := relatedType(t)
+	if related == nil {
+		return nil
+	}
+
+	id, ok := rc.ids[related]
+	if !ok {
+		coll, err := findCollection(rc.dao, reflect.Zero(related).Interface().(Entity).CollectionName())
+		if err != nil {
+			return fmt.Errorf("could not get collection of %s: %w", related, err)
+		}
+		id = coll.Id
+		rc.ids[related] = id
+	}
+
+	if id != fieldType.Options.(*schema.RelationOptions).CollectionId {
+		return fmt.Errorf("%s is not an entity of the related collection of %q", related, fieldType.Name)
+	}
+	return nil
+}
+
+// checkRelationCount returns an error if the number of ids is out of the limits of the relation options.
+// No ids are always allowed, as a blank value.
+func checkRelationCount(options *schema.RelationOptions, ids []string) error {
+	count := len(ids)
+	if count == 0 {
+		return nil
+	}
+
+	if options.MinSelect != nil && count < *options.MinSelect {
+		return fmt.Errorf("%d related records, expected at least %d", count, *options.MinSelect)
+	}
+	if options.MaxSelect != nil && count > *options.MaxSelect {
+		return fmt.Errorf("%d related records, expected at most %d", count, *options.MaxSelect)
+	}
+	return nil
+}
+
+// newRelatedEntity returns a new pointer of type t (e.g. *Author) to the entity identified by id.
+// If expanded is not nil, it is decoded into the entity, otherwise only the entity id is set.
+// The entity is returned along with the *MappingError of the fields which could not be decoded, if any.
+func newRelatedEntity(t reflect.Type, id string, expanded *models.Record, opts *mappingOptions) (reflect.Value, error) {
+	related := reflect.New(t.Elem())
+
+	if expanded == nil {
+		setEntityId(related.Elem(), id)
+		return related, nil
+	}
+
+	collectionName := related.Interface().(Entity).CollectionName()
+	if coll := expanded.Collection(); coll == nil || (coll.Name != collectionName && coll.Id != collectionName) {
+		return reflect.Value{}, &fatalError{fmt.Errorf("expanded record %q does not belong to %s collection %q", id, t.Elem(), collectionName)}
+	}
+
+	err := decodeStruct(expanded, related.Elem(), opts)
+	if _, ok := err.(*fatalError); ok {
+		return reflect.Value{}, &fatalError{fmt.Errorf("could not decode expanded record %q: %w", id, err)}
+	}
+
+	return related, err
+}
+
+// holdsEntity reports whether item, an entity pointer or a Ref, already holds the entity identified by id.
+func holdsEntity(item reflect.Value, id string) bool {
+	entityField := item
+	if isRef(item.Type()) {
+		entityField = item.FieldByName("Entity")
+	}
+	return !entityField.IsNil() && entityId(entityField.Elem()) == id
+}
+
+// setRelated sets item, an entity pointer or a Ref, to the entity identified by id, decoded from expanded
+// when not nil. Without expanded record, the entity item already holds is kept if it has the same id;
+// otherwise an entity pointer only holds the id while a Ref holds no entity.
+func setRelated(item reflect.Value, id string, expanded *models.Record, opts *mappingOptions) error {
+	if expanded == nil && holdsEntity(item, id) {
+		if isRef(item.Type()) {
+			item.FieldByName("Id").SetString(id)
+		}
+		return nil
+	}
+
+	entityField := item
+	if isRef(item.Type()) {
+		item.Set(reflect.Zero(item.Type()))
+		item.FieldByName("Id").SetString(id)
+		if expanded == nil {
+			return nil
+		}
+		entityField = item.FieldByName("Entity")
+	}
+
+	related, err := newRelatedEntity(entityField.Type(), id, expanded, opts)
+	if _, ok := err.(*fatalError); ok {
+		return err
+	}
+
+	entityField.Set(related)
+	return err
+}
+
+// decodeSingleRelation sets entityField, an entity pointer or a Ref, to the entity identified by id,
+// decoded from the record expand data when available, see setRelated.
+func decodeSingleRelation(record *models.Record, columnName string, id string, entityField reflect.Value, opts *mappingOptions) error {
+	if id == "" {
+		entityField.Set(reflect.Zero(entityField.Type()))
+		return nil
+	}
+
+	expanded, _ := record.Expand()[columnName].(*models.Record)
+	err := setRelated(entityField, id, expanded, opts)
+	if _, ok := err.(*fatalError); ok {
+		return &fatalError{fmt.Errorf("could not decode relation %q: %w", columnName, err)}
+	}
+
+	return err
+}
+
+// decodeMultipleRelation sets entityField, a slice of entity pointers or a Refs, to the entities identified
+// by ids, decoded from the record expand data when available. The entities entityField already holds are
+// kept when not expanded, see setRelated.
+func decodeMultipleRelation(record *models.Record, columnName string, ids []string, entityField reflect.Value, opts *mappingOptions) error {
+	expandedById := map[string]*models.Record{}
+	switch expand := record.Expand()[columnName].(type) {
+	case []*models.Record:
+		for _, expanded := range expand {
+			expandedById[expanded.Id] = expanded
+		}
+	case *models.Record:
+		expandedById[expand.Id] = expand
+	}
+
+	mappingErr := &MappingError{Entity: relatedType(entityField.Type()).Name()}
+	relatedSlice := reflect.MakeSlice(entityField.Type(), 0, len(ids))
+	for i, id := range ids {
+		related := reflect.New(entityField.Type().Elem()).Elem()
+		for j := 0; j < entityField.Len(); j++ {
+			if holdsEntity(entityField.Index(j), id) {
+				related.Set(entityField.Index(j))
+				break
+			}
+		}
+
+		err := setRelated(related, id, expandedById[id], opts)
+		switch err := err.(type) {
+		case *fatalError:
+			return &fatalError{fmt.Errorf("could not decode relation %q: %w", columnName, err)}
+		case *MappingError:
+			for _, nested := range err.Fields {
+				nestedErr := *nested
+				nestedErr.Field = fmt.Sprintf("[%d].%s", i, nested.Field)
+				mappingErr.Fields = append(mappingErr.Fields, &nestedErr)
+			}
+		}
+		relatedSlice = reflect.Append(relatedSlice, related)
+	}
+
+	entityField.Set(relatedSlice)
+	return mappingErr.errOrNil()
+}
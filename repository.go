@@ -0,0 +1,234 @@
+package orm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+// Repository provides typed CRUD operations for the entity T
+// on top of its PocketBase collection.
+type Repository[T Entity] struct {
+	dao           *daos.Dao
+	expands       []string
+	opts          []Option
+	newFilesystem func() (*filesystem.System, error)
+}
+
+// NewRepository returns a new Repository of T bound to the given dao,
+// encoding and decoding its entities with opts (e.g. Strict).
+func NewRepository[T Entity](dao *daos.Dao, opts ...Option) *Repository[T] {
+	return &Repository[T]{dao: dao, opts: opts}
+}
+
+// With returns a copy of the repository whose finders eager load the given relation paths
+// (e.g. "author", "tags.owner") into the nested entities of the returned ones.
+func (r *Repository[T]) With(paths ...string) *Repository[T] {
+	repository := *r
+	repository.expands = make([]string, 0, len(r.expands)+len(paths))
+	repository.expands = append(repository.expands, r.expands...)
+	repository.expands = append(repository.expands, paths...)
+
+	return &repository
+}
+
+// WithFilesystem returns a copy of the repository uploading the pending files of saved entities
+// into the filesystem returned by newFilesystem (e.g. app.NewFilesystem).
+func (r *Repository[T]) WithFilesystem(newFilesystem func() (*filesystem.System, error)) *Repository[T] {
+	repository := *r
+	repository.newFilesystem = newFilesystem
+
+	return &repository
+}
+
+// CollectionName returns the name of the collection T is stored in.
+func (r *Repository[T]) CollectionName() string {
+	var zeroValue T
+	return zeroValue.CollectionName()
+}
+
+// FindById returns the entity identified by id.
+func (r *Repository[T]) FindById(id string) (*T, error) {
+	if r.dao == nil {
+		return nil, fmt.Errorf("could not find entity: dao is nil")
+	}
+
+	record, err := r.dao.FindRecordById(r.CollectionName(), id)
+	if err != nil {
+		return nil, fmt.Errorf("could not find record %q: %w", id, err)
+	}
+
+	entities, err := decodeRecords[T](r.dao, []*models.Record{record}, r.expands, r.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode record %q: %w", id, err)
+	}
+
+	return entities[0], nil
+}
+
+// FindAll returns every entity of the collection matching all the given expressions.
+// It returns all the collection entities if no expression is provided.
+func (r *Repository[T]) FindAll(exprs ...dbx.Expression) ([]*T, error) {
+	if r.dao == nil {
+		return nil, fmt.Errorf("could not find entities: dao is nil")
+	}
+
+	records, err := r.dao.FindRecordsByExpr(r.CollectionName(), exprs...)
+	if err != nil {
+		return nil, fmt.Errorf("could not find records: %w", err)
+	}
+
+	return decodeRecords[T](r.dao, records, r.expands, r.opts...)
+}
+
+// FindByFilter returns the entities matching the given PocketBase filter expression, see FindByFilter.
+func (r *Repository[T]) FindByFilter(filter string, sort string, limit int, offset int, params ...dbx.Params) ([]*T, error) {
+	return findByFilter[T](r.dao, r.expands, r.opts, filter, sort, limit, offset, params...)
+}
+
+// FindPage returns the given page of entities matching the given PocketBase filter expression, see FindPage.
+func (r *Repository[T]) FindPage(page int, perPage int, filter string, sort string, params ...dbx.Params) (*Page[T], error) {
+	return findPage[T](r.dao, r.expands, r.opts, page, perPage, filter, sort, params...)
+}
+
+// Exists reports whether an entity identified by id exists.
+func (r *Repository[T]) Exists(id string) (bool, error) {
+	if r.dao == nil {
+		return false, fmt.Errorf("could not check entity existence: dao is nil")
+	}
+
+	if id == "" {
+		return false, nil
+	}
+
+	_, err := r.dao.FindRecordById(r.CollectionName(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("could not find record %q: %w", id, err)
+	}
+
+	return true, nil
+}
+
+// Save creates the entity if it is not persisted yet, or updates it otherwise.
+// Generated values (such as the id of a created entity) are written back into entity.
+// The columns of the stored record which are not mapped by entity keep their values, and so do the
+// columns left unchanged by an entity embedding Tracked, see Changes.
+//
+// Pending file uploads are checked against the options of their field, then written to the repository
+// filesystem, see WithFilesystem. The files the entity replaced or removed are deleted from it.
+func (r *Repository[T]) Save(entity *T) error {
+	if r.dao == nil {
+		return fmt.Errorf("could not encode: dao is nil")
+	}
+
+	if entity == nil {
+		return fmt.Errorf("could not encode nil entity")
+	}
+
+	// the stored record is read and updated in the same transaction, so that the concurrent saves
+	// don't overwrite the columns changed in between
+	var record *models.Record
+	var removed []string
+	err := r.dao.RunInTransaction(func(txDao *daos.Dao) error {
+		var err error
+		record, err = r.recordOf(txDao, entity)
+		if err != nil {
+			return err
+		}
+
+		// a tracked entity only updates the columns it changed, keeping the ones changed by others
+		opts := r.opts
+		if snapshot := snapshotOf(reflect.ValueOf(entity).Elem()); snapshot != nil && !record.IsNew() && snapshot.Id == record.Id {
+			opts = append(opts[:len(opts):len(opts)], changedSince(snapshot))
+		}
+
+		previous := storedFiles(record)
+		if err := encodeInto(entity, record, newRelatedCollections(r.dao), newMappingOptions(opts)); err != nil {
+			return err
+		}
+
+		uploads := pendingUploads(reflect.ValueOf(entity).Elem(), record.Collection().Schema)
+		if err := checkFiles(record, uploads); err != nil {
+			return err
+		}
+
+		removed = removedFiles(previous, record)
+		if (len(uploads) > 0 || len(removed) > 0) && r.newFilesystem == nil {
+			return fmt.Errorf("could not update files: repository has no filesystem")
+		}
+
+		if err := txDao.SaveRecord(record); err != nil {
+			return fmt.Errorf("could not save record: %w", err)
+		}
+
+		return uploadFiles(r.newFilesystem, record, uploads)
+	})
+	if err != nil {
+		return err
+	}
+
+	// the replaced and removed files are deleted once the record no longer references them
+	if err := Decode(record, entity, r.opts...); err != nil {
+		return err
+	}
+	return deleteFiles(r.newFilesystem, record, removed)
+}
+
+// Delete removes the entity from its collection.
+func (r *Repository[T]) Delete(entity *T) error {
+	if r.dao == nil {
+		return fmt.Errorf("could not delete entity: dao is nil")
+	}
+
+	if entity == nil {
+		return fmt.Errorf("could not delete nil entity")
+	}
+
+	id := entityId(reflect.ValueOf(entity).Elem())
+	if id == "" {
+		return fmt.Errorf("could not delete entity without id")
+	}
+
+	stored, err := r.dao.FindRecordById(r.CollectionName(), id)
+	if err != nil {
+		return fmt.Errorf("could not find record %q: %w", id, err)
+	}
+
+	if err := r.dao.DeleteRecord(stored); err != nil {
+		return fmt.Errorf("could not delete record %q: %w", id, err)
+	}
+
+	return nil
+}
+
+// recordOf returns the stored record of entity read through dao, so that its columns not mapped by entity
+// are kept, or a new record when entity has no id or is not stored yet.
+func (r *Repository[T]) recordOf(dao *daos.Dao, entity *T) (*models.Record, error) {
+	if id := entityId(reflect.ValueOf(entity).Elem()); id != "" {
+		stored, err := dao.FindRecordById(r.CollectionName(), id)
+		if err == nil {
+			return stored, nil
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not find record %q: %w", id, err)
+		}
+	}
+
+	coll, err := findCollection(r.dao, r.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("could not get entity collection: %w", err)
+	}
+	return models.NewRecord(coll), nil
+}
+
+// Query returns a new QueryBuilder of T sharing the repository dao.
+func (r *Repository[T]) Query() *QueryBuilder[T] {
+	return Query[T](r.dao, r.opts...).With(r.expands...)
+}
@@ -0,0 +1,472 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestRepositorySaveAndFindById(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	repository := NewRepository[EntityWithAllPBTypes](testApp.Dao())
+
+	entity := entityExample
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := repository.FindById(entityExample.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := entityExample
+	if !reflect.DeepEqual(*actual, expected) {
+		t.Errorf("expected %v, got %v", expected, *actual)
+	}
+}
+
+func TestRepositorySaveGeneratesId(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	repository := NewRepository[EntityWithAllPBTypes](testApp.Dao())
+
+	entity := EntityWithAllPBTypes{Text: "foo"}
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if entity.Id == "" {
+		t.Fatalf("expected generated id, got empty string")
+	}
+
+	exists, err := repository.Exists(entity.Id)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if !exists {
+		t.Errorf("expected entity %q to exist", entity.Id)
+	}
+}
+
+func TestRepositorySaveUpdatesExisting(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	repository := NewRepository[EntityWithAllPBTypes](testApp.Dao())
+
+	entity := entityExample
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	entity.Text = "updated"
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	all, err := repository.FindAll()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actualLen := len(all); actualLen != 1 {
+		t.Fatalf("expected 1 element, got %d", actualLen)
+	}
+
+	if actual := all[0].Text; actual != "updated" {
+		t.Errorf("expected %q, got %q", "updated", actual)
+	}
+}
+
+func TestRepositorySaveKeepsUnmappedColumns(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity := entityExample
+	if err := NewRepository[EntityWithAllPBTypes](testApp.Dao()).Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	partial := PartialEntity{Id: entity.Id, Text: "updated"}
+	if err := NewRepository[PartialEntity](testApp.Dao()).Save(&partial); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := NewRepository[EntityWithAllPBTypes](testApp.Dao()).FindById(entity.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := entityExample
+	expected.Text = "updated"
+	expected.NumberInt = 0
+	if !reflect.DeepEqual(*actual, expected) {
+		t.Errorf("expected %v, got %v", expected, *actual)
+	}
+}
+
+func TestRepositorySaveTrackedChangesOnly(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	stored := entityExample
+	if err := NewRepository[EntityWithAllPBTypes](testApp.Dao()).Save(&stored); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	repository := NewRepository[TrackedEntity](testApp.Dao())
+
+	first, err := repository.FindById(stored.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	second, err := repository.FindById(stored.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	first.Text = "updated"
+	if err := repository.Save(first); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	second.Bool = false
+	if err := repository.Save(second); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := repository.FindById(stored.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual.Text != "updated" || actual.Bool {
+		t.Errorf("expected both changes to be saved, got text %q and bool %v", actual.Text, actual.Bool)
+	}
+
+	if second.Text != actual.Text {
+		t.Errorf("expected saved entity to be refreshed with text %q, got %q", actual.Text, second.Text)
+	}
+
+	if changes, err := Changes(second); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes after save, got %v (%v)", changes, err)
+	}
+}
+
+func TestRepositorySaveTrackedOverlapping(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	stored := entityExample
+	if err := NewRepository[EntityWithAllPBTypes](testApp.Dao()).Save(&stored); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	repository := NewRepository[TrackedEntity](testApp.Dao())
+
+	first, err := repository.FindById(stored.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	second, err := repository.FindById(stored.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the first save is held while it updates the record, so that the second one starts in between
+	updating := make(chan struct{})
+	var once sync.Once
+	testApp.OnModelBeforeUpdate().Add(func(e *core.ModelEvent) error {
+		once.Do(func() {
+			close(updating)
+			time.Sleep(100 * time.Millisecond)
+		})
+		return nil
+	})
+
+	firstErr := make(chan error)
+	go func() {
+		first.Text = "updated"
+		firstErr <- repository.Save(first)
+	}()
+
+	<-updating
+	second.Bool = false
+	if err := repository.Save(second); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := <-firstErr; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := repository.FindById(stored.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual.Text != "updated" || actual.Bool {
+		t.Errorf("expected both changes to be saved, got text %q and bool %v", actual.Text, actual.Bool)
+	}
+}
+
+func TestRepositoryDelete(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	repository := NewRepository[EntityWithAllPBTypes](testApp.Dao())
+
+	entity := entityExample
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := repository.Delete(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	exists, err := repository.Exists(entity.Id)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if exists {
+		t.Errorf("expected entity %q to be deleted", entity.Id)
+	}
+
+	if _, err := repository.FindById(entity.Id); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestRepositoryDeleteStrictIgnoresInvalidFields(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity := entityExample
+	if err := NewRepository[EntityWithAllPBTypes](testApp.Dao()).Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	repository := NewRepository[EntityWithSchemaMismatches](testApp.Dao(), Strict())
+
+	invalid := EntityWithSchemaMismatches{Id: entity.Id, Text: 12}
+	if err := repository.Save(&invalid); err == nil {
+		t.Fatalf("expected invalid entity not to be encoded, got nil error")
+	}
+
+	if err := repository.Delete(&invalid); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	exists, err := repository.Exists(entity.Id)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if exists {
+		t.Errorf("expected entity %q to be deleted", entity.Id)
+	}
+
+	if err := repository.Delete(&EntityWithSchemaMismatches{}); err == nil {
+		t.Errorf("expected error on entity without id, got nil")
+	}
+}
+
+func TestRepositorySaveFillsSystemFields(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithSystemFields{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	repository := NewRepository[EntityWithSystemFields](testApp.Dao())
+
+	first := EntityWithSystemFields{Title: "first"}
+	if err := repository.Save(&first); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if first.Created.IsZero() || first.Updated == nil || first.Updated.IsZero() {
+		t.Errorf("expected created and updated to be set, got %v and %v", first.Created, first.Updated)
+	}
+
+	created := first.Created
+	first.Title = "updated"
+	if err := repository.Save(&first); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// dates are stored with a millisecond precision
+	if !first.Created.Truncate(time.Millisecond).Equal(created.Truncate(time.Millisecond)) {
+		t.Errorf("expected created to be kept as %v, got %v", created, first.Created)
+	}
+
+	entities, err := repository.Query().OrderBy("-created").All()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(entities) != 1 || entities[0].CollName != "systems" {
+		t.Errorf("expected one entity of collection systems, got %v", entities)
+	}
+}
+
+func TestRepositoryEmbeddedStructs(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithSystemFields{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	repository := NewRepository[EntityWithEmbeds](testApp.Dao(), Strict())
+
+	entity := EntityWithEmbeds{Title: "embedded"}
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if entity.Id == "" || entity.Created.IsZero() {
+		t.Errorf("expected embedded id and created to be set, got %v", entity.BaseEntity)
+	}
+
+	actual, err := repository.FindById(entity.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual.Title != "embedded" || actual.Id != entity.Id {
+		t.Errorf("expected %v, got %v", entity, actual)
+	}
+
+	if actual.CollectionInfo == nil || actual.CollName != "systems" {
+		t.Errorf("expected embedded pointer to be allocated, got %v", actual.CollectionInfo)
+	}
+
+	if err := Validate[EntityWithEmbeds](testApp.Dao()); err != nil {
+		t.Errorf("expected no validation error, got %v", err)
+	}
+}
+
+func TestRepositoryStrict(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	entity := entityExample
+	if err := NewRepository[EntityWithAllPBTypes](testApp.Dao()).Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	repository := NewRepository[EntityWithSchemaMismatches](testApp.Dao(), Strict())
+
+	var mappingErr *MappingError
+	if _, err := repository.FindById(entity.Id); !errors.As(err, &mappingErr) {
+		t.Errorf("expected *MappingError, got %v", err)
+	}
+
+	if _, err := repository.Query().All(); !errors.As(err, &mappingErr) {
+		t.Errorf("expected *MappingError, got %v", err)
+	}
+
+	if err := repository.Save(&EntityWithSchemaMismatches{Text: 1}); !errors.As(err, &mappingErr) {
+		t.Errorf("expected *MappingError, got %v", err)
+	}
+}
+
+func TestRepositoryNilInlinedPointers(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithInlineStructs{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	repository := NewRepository[EntityWithInlineStructs](testApp.Dao(), Strict())
+
+	entity := EntityWithInlineStructs{Name: "foo"}
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if entity.Billing != nil {
+		t.Errorf("expected blank billing columns to decode into nil, got %v", entity.Billing)
+	}
+
+	entity.Billing = &Address{Street: "s", Geo: Geo{Lat: 1.5}}
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	actual, err := repository.FindById(entity.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := &Address{Street: "s", Geo: Geo{Lat: 1.5}}
+	if !reflect.DeepEqual(actual.Billing, expected) {
+		t.Errorf("expected %v, got %v", expected, actual.Billing)
+	}
+
+	entity.Billing = nil
+	if err := repository.Save(&entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	record, err := testApp.Dao().FindRecordById(entity.CollectionName(), entity.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if street, lat := record.GetString("billing_street"), record.GetFloat("billing_geo_lat"); street != "" || lat != 0 {
+		t.Errorf("expected billing columns to be blanked, got %q and %v", street, lat)
+	}
+
+	// an entity reused for decoding drops the address of blank columns
+	reused := EntityWithInlineStructs{Billing: &Address{City: "Lyon"}}
+	if err := Decode(record, &reused, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if reused.Billing != nil {
+		t.Errorf("expected blank billing columns to decode into nil, got %v", reused.Billing)
+	}
+}
@@ -1,31 +1,32 @@
 package orm
 
-import "github.com/fatih/structtag"
+import (
+	"reflect"
 
-func extractOrmNameFromTag(rawTag string) string {
+	"github.com/fatih/structtag"
+)
+
+// parseOrmTag returns the column name and the options of the orm tag held by rawTag.
+// The column name is empty if there is no valid orm tag.
+func parseOrmTag(rawTag string) (string, []string) {
 	tags, err := structtag.Parse(rawTag)
 	if err != nil || tags == nil {
-		return ""
+		return "", nil
 	}
 
 	ormTag, err := tags.Get("orm")
 	if err != nil || ormTag == nil {
-		return ""
+		return "", nil
 	}
 
-	return ormTag.Name
+	return ormTag.Name, ormTag.Options
 }
 
-func isOmitable(rawTag string) bool {
-	tags, err := structtag.Parse(rawTag)
-	if err != nil || tags == nil {
-		return true
-	}
-
-	ormTag, err := tags.Get("orm")
-	if err != nil || ormTag == nil {
-		return true
+// hasOrmName reports whether one of the fields of the structure type t is tagged with the given orm name.
+func hasOrmName(t reflect.Type, name string) bool {
+	if t.Kind() != reflect.Struct || name == "" {
+		return false
 	}
 
-	return ormTag.HasOption("omitempty")
+	return planOf(t).field(name) != nil
 }
@@ -0,0 +1,147 @@
+package orm
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// The fields of the text columns (i.e. text, email, url, editor, select and relation) are mapped,
+// by order of precedence, with:
+//  1. the registered converters and the FieldEncoder and FieldDecoder methods, see RegisterConverter;
+//  2. the encoding.TextMarshaler and encoding.TextUnmarshaler methods;
+//  3. the underlying string of the string based types.
+//
+// The fields of the JSON columns are mapped as encoding/json does, after the converters: with the
+// json.Marshaler and json.Unmarshaler methods, then the encoding.TextMarshaler and
+// encoding.TextUnmarshaler methods, then the default JSON encoding of their type.
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isTextType reports whether the fields of type t can map a text column, i.e. t is string based
+// or implements both encoding.TextMarshaler and encoding.TextUnmarshaler.
+func isTextType(t reflect.Type) bool {
+	if t.Kind() == reflect.String {
+		return true
+	}
+
+	marshaler := t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+	unmarshaler := reflect.PointerTo(t).Implements(textUnmarshalerType) ||
+		(t.Kind() == reflect.Pointer && t.Implements(textUnmarshalerType))
+	return marshaler && unmarshaler
+}
+
+// isTextSlice reports whether the fields of type t can map a multiple text column.
+func isTextSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && isTextType(t.Elem())
+}
+
+// textOf returns the text of v, an encoding.TextMarshaler or a string based value.
+// It returns false if v is neither of them. A nil pointer has no text.
+func textOf(v reflect.Value) (string, bool, error) {
+	t := v.Type()
+
+	switch {
+	case t.Implements(textMarshalerType):
+		if t.Kind() == reflect.Pointer && v.IsNil() {
+			return "", true, nil
+		}
+		return marshalText(v.Interface().(encoding.TextMarshaler))
+
+	case reflect.PointerTo(t).Implements(textMarshalerType):
+		if !v.CanAddr() {
+			copied := reflect.New(t)
+			copied.Elem().Set(v)
+			v = copied.Elem()
+		}
+		return marshalText(v.Addr().Interface().(encoding.TextMarshaler))
+
+	case t.Kind() == reflect.String:
+		return v.String(), true, nil
+	}
+
+	return "", false, nil
+}
+
+// marshalText returns the text of m.
+func marshalText(m encoding.TextMarshaler) (string, bool, error) {
+	text, err := m.MarshalText()
+	if err != nil {
+		return "", true, fmt.Errorf("could not marshal %v: %w", m, err)
+	}
+	return string(text), true, nil
+}
+
+// textsOf returns the texts of the elements of v, a slice of text values (see textOf).
+// It returns false if v is not such a slice.
+func textsOf(v reflect.Value) ([]string, bool, error) {
+	if v.Kind() != reflect.Slice || !isTextType(v.Type().Elem()) {
+		return nil, false, nil
+	}
+
+	texts := make([]string, v.Len())
+	for i := range texts {
+		text, _, err := textOf(v.Index(i))
+		if err != nil {
+			return nil, true, err
+		}
+		texts[i] = text
+	}
+	return texts, true, nil
+}
+
+// setText sets v, an encoding.TextUnmarshaler or a string based value, to text. An empty text sets v
+// to its zero value without unmarshaling it. It returns false if v is neither of them.
+func setText(v reflect.Value, text string) (bool, error) {
+	t := v.Type()
+
+	switch {
+	case text == "" && (reflect.PointerTo(t).Implements(textUnmarshalerType) || t.Implements(textUnmarshalerType)):
+		v.Set(reflect.Zero(t))
+		return true, nil
+
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return true, unmarshalText(v.Addr().Interface().(encoding.TextUnmarshaler), text)
+
+	case t.Kind() == reflect.Pointer && t.Implements(textUnmarshalerType):
+		ptr := reflect.New(t.Elem())
+		if err := unmarshalText(ptr.Interface().(encoding.TextUnmarshaler), text); err != nil {
+			return true, err
+		}
+		v.Set(ptr)
+		return true, nil
+
+	case t.Kind() == reflect.String:
+		v.SetString(text)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// unmarshalText sets u to text.
+func unmarshalText(u encoding.TextUnmarshaler, text string) error {
+	if err := u.UnmarshalText([]byte(text)); err != nil {
+		return fmt.Errorf("could not unmarshal %q: %w", text, err)
+	}
+	return nil
+}
+
+// setTexts sets v, a slice of text values (see setText), to texts. It returns false if v is not such a slice.
+func setTexts(v reflect.Value, texts []string) (bool, error) {
+	if v.Kind() != reflect.Slice || !isTextType(v.Type().Elem()) {
+		return false, nil
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(texts), len(texts))
+	for i, text := range texts {
+		if _, err := setText(slice.Index(i), text); err != nil {
+			return true, err
+		}
+	}
+	v.Set(slice)
+	return true, nil
+}
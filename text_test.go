@@ -0,0 +1,125 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalers(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithMarshalers{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	backup := Low
+	entity := EntityWithMarshalers{
+		Id:       "i7iedw7au80qljq",
+		Level:    High,
+		Levels:   []Level{Low, High},
+		Backup:   &backup,
+		Code:     "abc",
+		Position: Point{X: 1, Y: 2},
+		Origin:   Point{X: 3, Y: 4},
+	}
+
+	record, err := Encode(&entity, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	dataset := []struct {
+		label    string
+		column   string
+		expected string
+	}{
+		{"single select", "level", "high"},
+		{"multiple select", "levels", "[low high]"},
+		{"pointer", "backup", "low"},
+		{"MarshalText over string", "code", "ABC"},
+		{"MarshalJSON over MarshalText", "position", "[1,2]"},
+		{"MarshalText on text column", "origin", "3,4"},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if actual := fmt.Sprint(record.Get(data.column)); actual != data.expected {
+				t.Errorf("expected %s, got %s", data.expected, actual)
+			}
+		})
+	}
+
+	if err := testApp.Dao().SaveRecord(record); err != nil {
+		t.Fatalf("could not save record: %v", err)
+	}
+
+	saved, err := testApp.Dao().FindRecordById("marshalers", entity.Id)
+	if err != nil {
+		t.Fatalf("could not find record: %v", err)
+	}
+
+	actual := EntityWithMarshalers{}
+	if err := Decode(saved, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, entity) {
+		t.Errorf("expected %v, got %v", entity, actual)
+	}
+
+	if err := Validate[EntityWithMarshalers](testApp.Dao()); err != nil {
+		t.Errorf("expected no validation error, got %v", err)
+	}
+}
+
+func TestMarshalersEmptyText(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithMarshalers{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	record, err := Encode(&EntityWithMarshalers{}, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	record.Set("level", "")
+
+	actual := EntityWithMarshalers{Level: High}
+	if err := Decode(record, &actual, Strict()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if actual.Level != Low || actual.Backup != nil {
+		t.Errorf("expected zero level and nil backup, got %v", actual)
+	}
+}
+
+func TestMarshalersErrors(t *testing.T) {
+	testApp, err := setupTestApp(EntityWithMarshalers{}.Collection())
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if _, err := Encode(&EntityWithMarshalers{Level: Level(42)}, testApp.Dao(), Strict()); err == nil {
+		t.Errorf("expected marshal error, got nil")
+	}
+
+	record, err := Encode(&EntityWithMarshalers{}, testApp.Dao(), Strict())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	record.Set("levels", []string{"low", "medium"})
+
+	var mappingErr *MappingError
+	if err := Decode(record, &EntityWithMarshalers{}, Strict()); !errors.As(err, &mappingErr) {
+		t.Fatalf("expected *MappingError, got %v", err)
+	}
+
+	if len(mappingErr.Fields) != 1 || mappingErr.Fields[0].Field != "Levels" {
+		t.Errorf("expected Levels field error, got %v", mappingErr)
+	}
+}
@@ -0,0 +1,89 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// Tracked makes the entity embedding it remember the values it is decoded from, so that Changes lists
+// its modified columns and Repository.Save updates only them, keeping the columns modified in the
+// meantime by others.
+type Tracked struct {
+	snapshot *models.Record
+}
+
+var trackedType = reflect.TypeOf(Tracked{})
+
+// Change is a column whose value was modified since the entity was decoded.
+type Change struct {
+	Column string
+	Old    any
+	New    any
+}
+
+// Changes returns the columns mapped by entity whose values were modified since it was decoded, with their
+// decoded and current values. The entity must embed Tracked.
+func Changes[T Entity](entity *T, opts ...Option) ([]Change, error) {
+	if entity == nil {
+		return nil, fmt.Errorf("could not list changes of nil entity")
+	}
+
+	s := reflect.ValueOf(entity).Elem()
+	if s.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("entity given is not a structure")
+	}
+
+	if planOf(s.Type()).tracked == nil {
+		return nil, fmt.Errorf("could not list changes: %s does not embed orm.Tracked", s.Type())
+	}
+
+	snapshot := snapshotOf(s)
+	if snapshot == nil {
+		return nil, fmt.Errorf("could not list changes: entity was not decoded from a record")
+	}
+
+	columns, mappingErr := encodeColumns(s, snapshot.Collection().Schema, nil)
+	if err := newMappingOptions(opts).handle(mappingErr.errOrNil()); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, c := range columns {
+		if !hasChanged(snapshot, c.fieldType, c.value) {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Column: c.fieldType.Name,
+			Old:    c.fieldType.PrepareValue(snapshot.Get(c.fieldType.Name)),
+			New:    c.fieldType.PrepareValue(c.value),
+		})
+	}
+	return changes, nil
+}
+
+// track remembers record as the snapshot of the structure value s, if it embeds Tracked.
+func track(s reflect.Value, record *models.Record) {
+	fp := planOf(s.Type()).tracked
+	if fp == nil {
+		return
+	}
+
+	fp.settableValue(s).Addr().Interface().(*Tracked).snapshot = record.CleanCopy()
+}
+
+// snapshotOf returns the record the structure value s was decoded from, or nil if s is not tracked.
+func snapshotOf(s reflect.Value) *models.Record {
+	fp := planOf(s.Type()).tracked
+	if fp == nil {
+		return nil
+	}
+
+	v, ok := fp.value(s)
+	if !ok {
+		return nil
+	}
+	return v.Addr().Interface().(*Tracked).snapshot
+}
@@ -0,0 +1,111 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	stored := entityExample
+	if err := NewRepository[EntityWithAllPBTypes](testApp.Dao()).Save(&stored); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	entity, err := NewRepository[TrackedEntity](testApp.Dao()).FindById(stored.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	changes, err := Changes(entity)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes on a decoded entity, got %v", changes)
+	}
+
+	entity.Text = "updated"
+	entity.NumberInt = 3
+
+	changes, err = Changes(entity)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []Change{
+		{Column: "text", Old: "foo", New: "updated"},
+		{Column: "number_int", Old: -5.0, New: 3.0},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+}
+
+func TestChangesOfUntrackedEntities(t *testing.T) {
+	dataset := []struct {
+		label   string
+		changes func() ([]Change, error)
+	}{
+		{"without Tracked", func() ([]Change, error) { return Changes(&PartialEntity{}) }},
+		{"not decoded", func() ([]Change, error) { return Changes(&TrackedEntity{}) }},
+		{"nil entity", func() ([]Change, error) { return Changes[TrackedEntity](nil) }},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if _, err := data.changes(); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestChangesOfReformattedJSON(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	record, err := Encode(&PartialEntity{Id: "i7iedw7au80qljq"}, testApp.Dao())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	record.Set("json_object", `{"foo": 1, "bar": "x"}`)
+	if err := testApp.Dao().SaveRecord(record); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	repository := NewRepository[TrackedEntity](testApp.Dao())
+	entity, err := repository.FindById(record.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	changes, err := Changes(entity)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes on reformatted JSON, got %v", changes)
+	}
+
+	entity.Text = "updated"
+	if err := repository.Save(entity); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stored, err := testApp.Dao().FindRecordById("foo", record.Id)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if actual := stored.GetString("json_object"); actual != `{"foo": 1, "bar": "x"}` {
+		t.Errorf("expected unchanged JSON to be kept as stored, got %s", actual)
+	}
+}
@@ -0,0 +1,207 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// knownOptions are the orm tag options understood by the package, "key" standing for "key=<value>" options.
+var knownOptions = []string{
+	"omitempty", "required", "unique",
+	"type", "min", "max", "values", "maxSelect", "maxSize", "collection",
+	"inline", "prefix", "noDecimal",
+}
+
+// SchemaError is returned by Validate when the fields of an entity don't match the schema of its collection.
+type SchemaError struct {
+	Entity     string
+	Collection string
+	Problems   []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("entity %s does not match collection %q: %s", e.Entity, e.Collection, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the orm tags and the Go types of the fields of T against the schema of its collection.
+// It returns a *SchemaError listing the columns missing from the collection, the type mismatches
+// (e.g. an int field on a text column, or a string field on a multiple select) and the unknown tag options.
+func Validate[T Entity](dao *daos.Dao) error {
+	var zeroValue T
+	return validateEntity(dao, zeroValue)
+}
+
+// MustValidateAll validates entities with Validate once app is about to serve, i.e. after the migrations
+// are applied. The OnBeforeServe hook returns the error of the first entity not matching its collection,
+// which stops the app from serving with out of date entities.
+func MustValidateAll(app core.App, entities ...Entity) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		for _, entity := range entities {
+			if err := validateEntity(app.Dao(), entity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// validateEntity checks the fields of the structure type of entity against the schema of its collection.
+func validateEntity(dao *daos.Dao, entity Entity) error {
+	if dao == nil {
+		return fmt.Errorf("could not validate: dao is nil")
+	}
+
+	if entity == nil {
+		return fmt.Errorf("could not validate nil entity")
+	}
+
+	t := reflect.TypeOf(entity)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("entity given is not a structure")
+	}
+
+	coll, err := findCollection(dao, entity.CollectionName())
+	if err != nil {
+		return fmt.Errorf("could not get entity collection: %w", err)
+	}
+
+	problems := []string{}
+	for _, fp := range planOf(t).fields {
+		for _, option := range fp.options {
+			key, _, _ := strings.Cut(option, "=")
+			if !isKnownOption(key) {
+				problems = append(problems, fmt.Sprintf("field %s: unknown tag option %q", fp.name, option))
+			}
+		}
+
+		if fp.hasOption("inline") {
+			problems = append(problems, fmt.Sprintf("field %s: %s can't be inlined, expected a structure", fp.name, fp.typ))
+		}
+
+		if fp.readOnly {
+			if err := checkReadOnlyColumn(fp.columnName, fp.typ); err != nil {
+				problems = append(problems, fmt.Sprintf("field %s: %s can't map the %s column %q", fp.name, fp.typ, readOnlyColumnType(fp.columnName), fp.columnName))
+			}
+			continue
+		}
+
+		fieldType := fieldFromColumnName(coll.Schema, fp.columnName)
+		if fieldType == nil {
+			problems = append(problems, fmt.Sprintf("field %s: no column %q", fp.name, fp.columnName))
+			continue
+		}
+
+		if fp.custom {
+			continue
+		}
+
+		if err := checkFieldType(dao, fieldType, fp.typ); err != nil {
+			problems = append(problems, fmt.Sprintf("field %s: %v", fp.name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &SchemaError{Entity: t.Name(), Collection: coll.Name, Problems: problems}
+	}
+
+	return nil
+}
+
+// isKnownOption reports whether key is one of the knownOptions.
+func isKnownOption(key string) bool {
+	for _, option := range knownOptions {
+		if key == option {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFieldType returns an error if a field of type t can't be encoded into and decoded from the column fieldType.
+func checkFieldType(dao *daos.Dao, fieldType *schema.SchemaField, t reflect.Type) error {
+	if isNullablePointer(fieldType, t) {
+		if fieldType.Type == schema.FieldTypeNumber || fieldType.Type == schema.FieldTypeBool {
+			return fmt.Errorf("a nil %s can't be told from %v on the %s column %q, which is never null", t, fieldType.PrepareValue(nil), fieldType.Type, fieldType.Name)
+		}
+		return checkFieldType(dao, fieldType, t.Elem())
+	}
+
+	mismatch := fmt.Errorf("%s can't map the %s column %q", t, fieldType.Type, fieldType.Name)
+
+	isStringSlice := t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String
+	isTextsSlice := isTextSlice(t)
+
+	switch fieldType.Type {
+	case schema.FieldTypeText, schema.FieldTypeEmail, schema.FieldTypeUrl, schema.FieldTypeEditor:
+		if !isTextType(t) {
+			return mismatch
+		}
+
+	case schema.FieldTypeNumber:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			return mismatch
+		}
+
+	case schema.FieldTypeBool:
+		if t.Kind() != reflect.Bool {
+			return mismatch
+		}
+
+	case schema.FieldTypeDate:
+		if t != timeType && t != dateTimeType {
+			return mismatch
+		}
+
+	case schema.FieldTypeJson:
+		switch t.Kind() {
+		case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			return mismatch
+		}
+
+	case schema.FieldTypeRelation:
+		if (fieldType.Options.(*schema.RelationOptions)).IsMultiple() {
+			if !isTextsSlice && !isRelatedSlice(t) {
+				return fmt.Errorf("%s can't map the multiple relation column %q, expected a slice", t, fieldType.Name)
+			}
+		} else if !isTextType(t) && !isRelated(t) {
+			return mismatch
+		}
+
+		return checkRelatedCollection(dao, fieldType, t)
+
+	case schema.FieldTypeFile:
+		if (fieldType.Options.(*schema.FileOptions)).IsMultiple() {
+			if !isStringSlice && !(t.Kind() == reflect.Slice && t.Elem() == fileType) {
+				return fmt.Errorf("%s can't map the multiple file column %q, expected a slice", t, fieldType.Name)
+			}
+		} else if t.Kind() != reflect.String && t != fileType {
+			return mismatch
+		}
+
+	case schema.FieldTypeSelect:
+		if (fieldType.Options.(*schema.SelectOptions)).IsMultiple() {
+			if !isTextsSlice {
+				return fmt.Errorf("%s can't map the multiple select column %q, expected a slice", t, fieldType.Name)
+			}
+		} else if !isTextType(t) {
+			return mismatch
+		}
+
+		return checkEnumValues(fieldType, t)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+func TestValidate(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithAllPBTypes{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	dataset := []struct {
+		label    string
+		validate func(dao *daos.Dao) error
+	}{
+		{"all PocketBase types", Validate[EntityWithAllPBTypes]},
+		{"relations", Validate[Book]},
+		{"relation owner", Validate[Tag]},
+	}
+
+	for _, data := range dataset {
+		t.Run(data.label, func(t *testing.T) {
+			if err := data.validate(testApp.Dao()); err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateMismatches(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := testApp.Dao().SaveCollection(EntityWithAllPBTypes{}.Collection()); err != nil {
+		t.Fatalf("could not save collection: %v", err)
+	}
+
+	err = Validate[EntityWithSchemaMismatches](testApp.Dao())
+
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected *SchemaError, got %v", err)
+	}
+
+	expected := []string{
+		`field Text: int can't map the text column "text"`,
+		`field Renamed: no column "renamed"`,
+		`field MultipleSelect: string can't map the multiple select column "multiple_select", expected a slice`,
+		`field Bool: unknown tag option "omitemty"`,
+	}
+	if !reflect.DeepEqual(schemaErr.Problems, expected) {
+		t.Errorf("expected %q, got %q", expected, schemaErr.Problems)
+	}
+
+	if err := Validate[BookWithTagAsAuthor](testApp.Dao()); err == nil {
+		t.Errorf("expected related collection error, got nil")
+	}
+}
+
+func TestValidateSystemFields(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	err = Validate[EntityWithSystemFieldsMismatches](testApp.Dao())
+
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected *SchemaError, got %v", err)
+	}
+
+	expected := []string{
+		`field Created: int can't map the date column "created"`,
+		`field CollectionId: bool can't map the text column "collectionId"`,
+	}
+	if !reflect.DeepEqual(schemaErr.Problems, expected) {
+		t.Errorf("expected %q, got %q", expected, schemaErr.Problems)
+	}
+}
+
+func TestValidateUnknownCollection(t *testing.T) {
+	testApp, err := setupEncodeTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	if err := Validate[Book](testApp.Dao()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestMustValidateAll(t *testing.T) {
+	testApp, err := setupExpandTests()
+	if err != nil {
+		t.Fatalf("could not prepate esting environment: %v", err)
+	}
+	defer testApp.Cleanup()
+
+	MustValidateAll(testApp, Author{}, Tag{}, Book{})
+	if err := testApp.OnBeforeServe().Trigger(&core.ServeEvent{App: testApp}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	MustValidateAll(testApp, BookWithTagAsAuthor{})
+	err = testApp.OnBeforeServe().Trigger(&core.ServeEvent{App: testApp})
+
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Errorf("expected *SchemaError, got %v", err)
+	}
+}